@@ -0,0 +1,397 @@
+package parser
+
+// builtInCodeSets returns the reference tables bundled with the tool.
+// They follow the TEC SDR manual; when TEC revises a table, export the tables with
+// -export-codes, edit the CSV and load it with -codes rather than waiting for a release.
+func builtInCodeSets() map[string]CodeSet {
+	sets := []CodeSet{
+		{Name: "YES_NO", Codes: []Code{
+			{"Y", "Yes"},
+			{"N", "No"},
+		}},
+		{Name: "GENDER", Codes: []Code{
+			{"F", "Female"},
+			{"M", "Male"},
+			{"D", "Gender diverse"},
+		}},
+		{Name: "PRIOR_A", Codes: []Code{
+			{"01", "Secondary school student"},
+			{"02", "University student"},
+			{"03", "Polytechnic student"},
+			{"04", "College of education student"},
+			{"05", "Wānanga student"},
+			{"06", "Private training establishment student"},
+			{"07", "Wage or salary earner"},
+			{"08", "Self-employed"},
+			{"09", "Unemployed"},
+			{"10", "Beneficiary"},
+			{"11", "Home-maker or retired"},
+			{"12", "Overseas"},
+			{"13", "Other"},
+			{"98", "Not known"},
+		}},
+		{Name: "SEC_QUAL", Codes: []Code{
+			{"00", "No secondary school qualification"},
+			{"01", "NCEA Level 1"},
+			{"02", "NCEA Level 2"},
+			{"03", "NCEA Level 3"},
+			{"04", "University Entrance"},
+			{"05", "Overseas secondary school qualification"},
+			{"06", "International Baccalaureate"},
+			{"07", "Cambridge International Examinations"},
+			{"08", "Other secondary school qualification"},
+			{"09", "Not known"},
+		}},
+		{Name: "DISABILITY", Codes: []Code{
+			{"1", "Yes"},
+			{"2", "No"},
+			{"9", "Not stated"},
+		}},
+		{Name: "ATTEND", Codes: []Code{
+			{"1", "Intramural"},
+			{"2", "Extramural"},
+		}},
+		{Name: "FUNDING", Codes: []Code{
+			{"01", "Student Achievement Component"},
+			{"02", "International fee-paying"},
+			{"03", "Domestic full fee-paying"},
+			{"04", "Exchange student"},
+			{"10", "Youth Guarantee"},
+			{"29", "Industry training"},
+			{"31", "Adult and community education"},
+			{"37", "Student Achievement Component (sub-degree)"},
+			{"99", "Other funding"},
+		}},
+		{Name: "CATEGORY", Codes: fundingCategories()},
+		{Name: "COMPLETE", Codes: []Code{
+			{"0", "Result not yet known"},
+			{"1", "Completed successfully"},
+			{"2", "Did not complete successfully"},
+			{"3", "Course continuing"},
+			{"4", "Withdrew"},
+		}},
+		{Name: "NZQCFLEVEL", Codes: []Code{
+			{"1", "Level 1"},
+			{"2", "Level 2"},
+			{"3", "Level 3"},
+			{"4", "Level 4"},
+			{"5", "Level 5"},
+			{"6", "Level 6"},
+			{"7", "Level 7"},
+			{"8", "Level 8"},
+			{"9", "Level 9"},
+		}},
+		// Stats NZ ethnicity classification, level 3, with its residual codes
+		{Name: "ETHNIC", Codes: []Code{
+			{"111", "New Zealand European"},
+			{"121", "British and Irish"},
+			{"122", "Dutch"},
+			{"123", "Greek"},
+			{"124", "Polish"},
+			{"125", "South Slav"},
+			{"126", "Italian"},
+			{"127", "German"},
+			{"128", "Australian"},
+			{"129", "Other European"},
+			{"211", "Māori"},
+			{"311", "Samoan"},
+			{"321", "Cook Islands Māori"},
+			{"331", "Tongan"},
+			{"341", "Niuean"},
+			{"351", "Tokelauan"},
+			{"361", "Fijian"},
+			{"371", "Other Pacific Peoples"},
+			{"411", "Filipino"},
+			{"412", "Cambodian"},
+			{"413", "Vietnamese"},
+			{"414", "Other Southeast Asian"},
+			{"421", "Chinese"},
+			{"431", "Indian"},
+			{"441", "Sri Lankan"},
+			{"442", "Japanese"},
+			{"443", "Korean"},
+			{"444", "Other Asian"},
+			{"511", "Middle Eastern"},
+			{"521", "Latin American"},
+			{"531", "African"},
+			{"611", "Other Ethnicity"},
+			{"941", "Don't Know"},
+			{"942", "Refused to Answer"},
+			{"943", "Repeated Value"},
+			{"944", "Response Unidentifiable"},
+			{"954", "Response Outside Scope"},
+			{"999", "Not Stated"},
+		}},
+		// The Stats NZ iwi classification is not bundled: save it as iwi.csv in the
+		// code table directory to label iwi codes and check them
+		{Name: "IWI"},
+		{Name: "COUNTRY", Codes: []Code{
+			{"AFG", "Afghanistan"},
+			{"ALA", "Åland Islands"},
+			{"ALB", "Albania"},
+			{"DZA", "Algeria"},
+			{"ASM", "American Samoa"},
+			{"AND", "Andorra"},
+			{"AGO", "Angola"},
+			{"AIA", "Anguilla"},
+			{"ATA", "Antarctica"},
+			{"ATG", "Antigua and Barbuda"},
+			{"ARG", "Argentina"},
+			{"ARM", "Armenia"},
+			{"ABW", "Aruba"},
+			{"AUS", "Australia"},
+			{"AUT", "Austria"},
+			{"AZE", "Azerbaijan"},
+			{"BHS", "Bahamas"},
+			{"BHR", "Bahrain"},
+			{"BGD", "Bangladesh"},
+			{"BRB", "Barbados"},
+			{"BLR", "Belarus"},
+			{"BEL", "Belgium"},
+			{"BLZ", "Belize"},
+			{"BEN", "Benin"},
+			{"BMU", "Bermuda"},
+			{"BTN", "Bhutan"},
+			{"BOL", "Bolivia"},
+			{"BES", "Bonaire, Sint Eustatius and Saba"},
+			{"BIH", "Bosnia and Herzegovina"},
+			{"BWA", "Botswana"},
+			{"BVT", "Bouvet Island"},
+			{"BRA", "Brazil"},
+			{"IOT", "British Indian Ocean Territory"},
+			{"BRN", "Brunei Darussalam"},
+			{"BGR", "Bulgaria"},
+			{"BFA", "Burkina Faso"},
+			{"BDI", "Burundi"},
+			{"CPV", "Cabo Verde"},
+			{"KHM", "Cambodia"},
+			{"CMR", "Cameroon"},
+			{"CAN", "Canada"},
+			{"CYM", "Cayman Islands"},
+			{"CAF", "Central African Republic"},
+			{"TCD", "Chad"},
+			{"CHL", "Chile"},
+			{"CHN", "China"},
+			{"CXR", "Christmas Island"},
+			{"CCK", "Cocos (Keeling) Islands"},
+			{"COL", "Colombia"},
+			{"COM", "Comoros"},
+			{"COG", "Congo"},
+			{"COD", "Congo, Democratic Republic of the"},
+			{"COK", "Cook Islands"},
+			{"CRI", "Costa Rica"},
+			{"CIV", "Côte d'Ivoire"},
+			{"HRV", "Croatia"},
+			{"CUB", "Cuba"},
+			{"CUW", "Curaçao"},
+			{"CYP", "Cyprus"},
+			{"CZE", "Czechia"},
+			{"DNK", "Denmark"},
+			{"DJI", "Djibouti"},
+			{"DMA", "Dominica"},
+			{"DOM", "Dominican Republic"},
+			{"ECU", "Ecuador"},
+			{"EGY", "Egypt"},
+			{"SLV", "El Salvador"},
+			{"GNQ", "Equatorial Guinea"},
+			{"ERI", "Eritrea"},
+			{"EST", "Estonia"},
+			{"SWZ", "Eswatini"},
+			{"ETH", "Ethiopia"},
+			{"FLK", "Falkland Islands"},
+			{"FRO", "Faroe Islands"},
+			{"FJI", "Fiji"},
+			{"FIN", "Finland"},
+			{"FRA", "France"},
+			{"GUF", "French Guiana"},
+			{"PYF", "French Polynesia"},
+			{"ATF", "French Southern Territories"},
+			{"GAB", "Gabon"},
+			{"GMB", "Gambia"},
+			{"GEO", "Georgia"},
+			{"DEU", "Germany"},
+			{"GHA", "Ghana"},
+			{"GIB", "Gibraltar"},
+			{"GRC", "Greece"},
+			{"GRL", "Greenland"},
+			{"GRD", "Grenada"},
+			{"GLP", "Guadeloupe"},
+			{"GUM", "Guam"},
+			{"GTM", "Guatemala"},
+			{"GGY", "Guernsey"},
+			{"GIN", "Guinea"},
+			{"GNB", "Guinea-Bissau"},
+			{"GUY", "Guyana"},
+			{"HTI", "Haiti"},
+			{"HMD", "Heard Island and McDonald Islands"},
+			{"VAT", "Holy See"},
+			{"HND", "Honduras"},
+			{"HKG", "Hong Kong"},
+			{"HUN", "Hungary"},
+			{"ISL", "Iceland"},
+			{"IND", "India"},
+			{"IDN", "Indonesia"},
+			{"IRN", "Iran"},
+			{"IRQ", "Iraq"},
+			{"IRL", "Ireland"},
+			{"IMN", "Isle of Man"},
+			{"ISR", "Israel"},
+			{"ITA", "Italy"},
+			{"JAM", "Jamaica"},
+			{"JPN", "Japan"},
+			{"JEY", "Jersey"},
+			{"JOR", "Jordan"},
+			{"KAZ", "Kazakhstan"},
+			{"KEN", "Kenya"},
+			{"KIR", "Kiribati"},
+			{"PRK", "Korea, Democratic People's Republic of"},
+			{"KOR", "Korea, Republic of"},
+			{"KWT", "Kuwait"},
+			{"KGZ", "Kyrgyzstan"},
+			{"LAO", "Lao People's Democratic Republic"},
+			{"LVA", "Latvia"},
+			{"LBN", "Lebanon"},
+			{"LSO", "Lesotho"},
+			{"LBR", "Liberia"},
+			{"LBY", "Libya"},
+			{"LIE", "Liechtenstein"},
+			{"LTU", "Lithuania"},
+			{"LUX", "Luxembourg"},
+			{"MAC", "Macao"},
+			{"MDG", "Madagascar"},
+			{"MWI", "Malawi"},
+			{"MYS", "Malaysia"},
+			{"MDV", "Maldives"},
+			{"MLI", "Mali"},
+			{"MLT", "Malta"},
+			{"MHL", "Marshall Islands"},
+			{"MTQ", "Martinique"},
+			{"MRT", "Mauritania"},
+			{"MUS", "Mauritius"},
+			{"MYT", "Mayotte"},
+			{"MEX", "Mexico"},
+			{"FSM", "Micronesia"},
+			{"MDA", "Moldova"},
+			{"MCO", "Monaco"},
+			{"MNG", "Mongolia"},
+			{"MNE", "Montenegro"},
+			{"MSR", "Montserrat"},
+			{"MAR", "Morocco"},
+			{"MOZ", "Mozambique"},
+			{"MMR", "Myanmar"},
+			{"NAM", "Namibia"},
+			{"NRU", "Nauru"},
+			{"NPL", "Nepal"},
+			{"NLD", "Netherlands"},
+			{"NCL", "New Caledonia"},
+			{"NZL", "New Zealand"},
+			{"NIC", "Nicaragua"},
+			{"NER", "Niger"},
+			{"NGA", "Nigeria"},
+			{"NIU", "Niue"},
+			{"NFK", "Norfolk Island"},
+			{"MKD", "North Macedonia"},
+			{"MNP", "Northern Mariana Islands"},
+			{"NOR", "Norway"},
+			{"OMN", "Oman"},
+			{"PAK", "Pakistan"},
+			{"PLW", "Palau"},
+			{"PSE", "Palestine, State of"},
+			{"PAN", "Panama"},
+			{"PNG", "Papua New Guinea"},
+			{"PRY", "Paraguay"},
+			{"PER", "Peru"},
+			{"PHL", "Philippines"},
+			{"PCN", "Pitcairn"},
+			{"POL", "Poland"},
+			{"PRT", "Portugal"},
+			{"PRI", "Puerto Rico"},
+			{"QAT", "Qatar"},
+			{"REU", "Réunion"},
+			{"ROU", "Romania"},
+			{"RUS", "Russian Federation"},
+			{"RWA", "Rwanda"},
+			{"BLM", "Saint Barthélemy"},
+			{"SHN", "Saint Helena, Ascension and Tristan da Cunha"},
+			{"KNA", "Saint Kitts and Nevis"},
+			{"LCA", "Saint Lucia"},
+			{"MAF", "Saint Martin (French part)"},
+			{"SPM", "Saint Pierre and Miquelon"},
+			{"VCT", "Saint Vincent and the Grenadines"},
+			{"WSM", "Samoa"},
+			{"SMR", "San Marino"},
+			{"STP", "Sao Tome and Principe"},
+			{"SAU", "Saudi Arabia"},
+			{"SEN", "Senegal"},
+			{"SRB", "Serbia"},
+			{"SYC", "Seychelles"},
+			{"SLE", "Sierra Leone"},
+			{"SGP", "Singapore"},
+			{"SXM", "Sint Maarten (Dutch part)"},
+			{"SVK", "Slovakia"},
+			{"SVN", "Slovenia"},
+			{"SLB", "Solomon Islands"},
+			{"SOM", "Somalia"},
+			{"ZAF", "South Africa"},
+			{"SGS", "South Georgia and the South Sandwich Islands"},
+			{"SSD", "South Sudan"},
+			{"ESP", "Spain"},
+			{"LKA", "Sri Lanka"},
+			{"SDN", "Sudan"},
+			{"SUR", "Suriname"},
+			{"SJM", "Svalbard and Jan Mayen"},
+			{"SWE", "Sweden"},
+			{"CHE", "Switzerland"},
+			{"SYR", "Syrian Arab Republic"},
+			{"TWN", "Taiwan"},
+			{"TJK", "Tajikistan"},
+			{"TZA", "Tanzania"},
+			{"THA", "Thailand"},
+			{"TLS", "Timor-Leste"},
+			{"TGO", "Togo"},
+			{"TKL", "Tokelau"},
+			{"TON", "Tonga"},
+			{"TTO", "Trinidad and Tobago"},
+			{"TUN", "Tunisia"},
+			{"TUR", "Türkiye"},
+			{"TKM", "Turkmenistan"},
+			{"TCA", "Turks and Caicos Islands"},
+			{"TUV", "Tuvalu"},
+			{"UGA", "Uganda"},
+			{"UKR", "Ukraine"},
+			{"ARE", "United Arab Emirates"},
+			{"GBR", "United Kingdom"},
+			{"USA", "United States of America"},
+			{"UMI", "United States Minor Outlying Islands"},
+			{"URY", "Uruguay"},
+			{"UZB", "Uzbekistan"},
+			{"VUT", "Vanuatu"},
+			{"VEN", "Venezuela"},
+			{"VNM", "Viet Nam"},
+			{"VGB", "Virgin Islands (British)"},
+			{"VIR", "Virgin Islands (U.S.)"},
+			{"WLF", "Wallis and Futuna"},
+			{"ESH", "Western Sahara"},
+			{"YEM", "Yemen"},
+			{"ZMB", "Zambia"},
+			{"ZWE", "Zimbabwe"},
+		}},
+	}
+
+	byName := make(map[string]CodeSet, len(sets))
+	for _, set := range sets {
+		byName[set.Name] = set
+	}
+	return byName
+}
+
+// fundingCategories returns the TEC funding categories: a subject area letter and a cost band digit
+func fundingCategories() []Code {
+	var codes []Code
+	for _, area := range "ABCEGHIJKLMNP" {
+		for band := '1'; band <= '4'; band++ {
+			codes = append(codes, Code{Code: string(area) + string(band)})
+		}
+	}
+	return codes
+}
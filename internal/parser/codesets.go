@@ -0,0 +1,225 @@
+package parser
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// codeSetFileExt is the extension of code table files in a code set directory
+const codeSetFileExt = ".csv"
+
+// Code is one allowed value of a coded field
+type Code struct {
+	Code  string
+	Label string
+}
+
+// CodeSet is a reference table of the values a coded field may take.
+// FieldSpec.CodeSet links a field to a code set by name.
+type CodeSet struct {
+	Name  string
+	Codes []Code
+}
+
+// Contains reports whether code is in the set
+func (c CodeSet) Contains(code string) bool {
+	_, ok := c.Label(code)
+	return ok
+}
+
+// Label returns the label of a code, and whether the code is in the set
+func (c CodeSet) Label(code string) (string, bool) {
+	for _, entry := range c.Codes {
+		if entry.Code == code {
+			return entry.Label, true
+		}
+	}
+	return "", false
+}
+
+// Values returns the codes of the set in table order
+func (c CodeSet) Values() []string {
+	values := make([]string, len(c.Codes))
+	for i, entry := range c.Codes {
+		values[i] = entry.Code
+	}
+	return values
+}
+
+// codeSets holds the code sets in use, keyed by name
+var codeSets = builtInCodeSets()
+
+// LookupCodeSet returns the code set with the given name
+func LookupCodeSet(name string) (CodeSet, bool) {
+	set, ok := codeSets[strings.ToUpper(name)]
+	return set, ok
+}
+
+// CodeSets returns the code sets in use, sorted by name
+func CodeSets() []CodeSet {
+	sets := make([]CodeSet, 0, len(codeSets))
+	for _, set := range codeSets {
+		sets = append(sets, set)
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i].Name < sets[j].Name })
+	return sets
+}
+
+// UseCodeSet installs a code set, replacing any set with the same name
+func UseCodeSet(set CodeSet) {
+	set.Name = strings.ToUpper(set.Name)
+	sets := make(map[string]CodeSet, len(codeSets)+1)
+	for name, existing := range codeSets {
+		sets[name] = existing
+	}
+	sets[set.Name] = set
+	codeSets = sets
+}
+
+// DefaultCodeSetDir returns the per-user directory searched for code table overrides
+func DefaultCodeSetDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config directory: %w", err)
+	}
+	return filepath.Join(configDir, "oh-no-sdr", "codes"), nil
+}
+
+// ReadCodeSetFile reads a code table from a CSV file with a "Code,Label" header.
+// The set is named after the file, so gender.csv holds the GENDER code set.
+func ReadCodeSetFile(path string) (CodeSet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return CodeSet{}, fmt.Errorf("failed to read code table: %w", err)
+	}
+	defer file.Close()
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	set := CodeSet{Name: strings.ToUpper(name)}
+
+	// Code tables are often kept in Excel, which may add a byte order mark or save in Windows-1252
+	decoded, _, _, err := decodeInput(file, EncodingAuto)
+	if err != nil {
+		return CodeSet{}, fmt.Errorf("failed to read code table: %w", err)
+	}
+	reader := csv.NewReader(decoded)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return CodeSet{}, fmt.Errorf("invalid code table %s: file is empty", filepath.Base(path))
+	}
+	if err != nil {
+		return CodeSet{}, fmt.Errorf("invalid code table %s: %w", filepath.Base(path), err)
+	}
+	if len(header) == 0 || !strings.EqualFold(strings.TrimSpace(header[0]), "Code") {
+		return CodeSet{}, fmt.Errorf("invalid code table %s: expected a Code,Label header", filepath.Base(path))
+	}
+
+	seen := make(map[string]bool)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return CodeSet{}, fmt.Errorf("invalid code table %s: %w", filepath.Base(path), err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		code := strings.TrimSpace(row[0])
+		if code == "" {
+			continue
+		}
+		if seen[code] {
+			return CodeSet{}, fmt.Errorf("invalid code table %s: line %d: code %s is listed more than once", filepath.Base(path), line, code)
+		}
+		seen[code] = true
+
+		entry := Code{Code: code}
+		if len(row) > 1 {
+			entry.Label = strings.TrimSpace(row[1])
+		}
+		set.Codes = append(set.Codes, entry)
+	}
+
+	if len(set.Codes) == 0 {
+		return CodeSet{}, fmt.Errorf("invalid code table %s: no codes", filepath.Base(path))
+	}
+	return set, nil
+}
+
+// WriteCodeSetFile writes a code set to a CSV file that ReadCodeSetFile can read back
+func WriteCodeSetFile(path string, set CodeSet) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write code table: %w", err)
+	}
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"Code", "Label"})
+	for _, entry := range set.Codes {
+		writer.Write([]string{entry.Code, entry.Label})
+	}
+	writer.Flush()
+
+	if err := errors.Join(writer.Error(), file.Close()); err != nil {
+		return fmt.Errorf("failed to write code table: %w", err)
+	}
+	return nil
+}
+
+// LoadCodeSetDir reads every CSV code table in a directory and installs it with UseCodeSet,
+// so TEC code changes take effect without rebuilding the binary.
+// Nothing is installed unless every code table is valid.
+func LoadCodeSetDir(dir string) ([]CodeSet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read code table directory: %w", err)
+	}
+
+	var sets []CodeSet
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), codeSetFileExt) {
+			continue
+		}
+
+		set, err := ReadCodeSetFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+
+	for _, set := range sets {
+		UseCodeSet(set)
+	}
+	return sets, nil
+}
+
+// ExportCodeSets writes every code set in use to <name>.csv in dir, creating dir if needed.
+// Empty sets are skipped. It returns the paths written.
+func ExportCodeSets(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create code table directory: %w", err)
+	}
+
+	var paths []string
+	for _, set := range CodeSets() {
+		if len(set.Codes) == 0 {
+			continue // Placeholder for a table that is not bundled; a file needs at least one code
+		}
+		path := filepath.Join(dir, strings.ToLower(set.Name)+codeSetFileExt)
+		if err := WriteCodeSetFile(path, set); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
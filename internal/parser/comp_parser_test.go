@@ -23,32 +23,32 @@ func TestCOMPParser_Parse(t *testing.T) {
 	}
 
 	// Test first record
-	if records[0]["INSTIT"] != "9170" {
-		t.Errorf("Expected INSTIT '9170', got '%s'", records[0]["INSTIT"])
+	if records[0].Get("INSTIT") != "9170" {
+		t.Errorf("Expected INSTIT '9170', got '%s'", records[0].Get("INSTIT"))
 	}
 
-	if records[0]["ID"] != "917000047" {
-		t.Errorf("Expected ID '917000047', got '%s'", records[0]["ID"])
+	if records[0].Get("ID") != "917000047" {
+		t.Errorf("Expected ID '917000047', got '%s'", records[0].Get("ID"))
 	}
 
-	if records[0]["COURSE"] != "2102-530" {
-		t.Errorf("Expected COURSE '2102-530', got '%s'", records[0]["COURSE"])
+	if records[0].Get("COURSE") != "2102-530" {
+		t.Errorf("Expected COURSE '2102-530', got '%s'", records[0].Get("COURSE"))
 	}
 
-	if records[0]["CRS_SRT"] != "28092023" {
-		t.Errorf("Expected CRS_SRT '28092023', got '%s'", records[0]["CRS_SRT"])
+	if records[0].Get("CRS_SRT") != "28092023" {
+		t.Errorf("Expected CRS_SRT '28092023', got '%s'", records[0].Get("CRS_SRT"))
 	}
 
-	if records[0]["NSN"] != "120331711" {
-		t.Errorf("Expected NSN '120331711', got '%s'", records[0]["NSN"])
+	if records[0].Get("NSN") != "120331711" {
+		t.Errorf("Expected NSN '120331711', got '%s'", records[0].Get("NSN"))
 	}
 
-	if records[0]["CRS_END"] != "06062024" {
-		t.Errorf("Expected CRS_END '06062024', got '%s'", records[0]["CRS_END"])
+	if records[0].Get("CRS_END") != "06062024" {
+		t.Errorf("Expected CRS_END '06062024', got '%s'", records[0].Get("CRS_END"))
 	}
 
-	if records[0]["PBRF_CRS_COMP_YR"] != "" {
-		t.Errorf("Expected PBRF_CRS_COMP_YR '', got '%s'", records[0]["PBRF_CRS_COMP_YR"])
+	if records[0].Get("PBRF_CRS_COMP_YR") != "" {
+		t.Errorf("Expected PBRF_CRS_COMP_YR '', got '%s'", records[0].Get("PBRF_CRS_COMP_YR"))
 	}
 }
 
@@ -98,32 +98,32 @@ func TestCOMPParser_ParseLine(t *testing.T) {
 	}
 
 	// Verify all fields are extracted correctly
-	if record["INSTIT"] != "9170" {
-		t.Errorf("INSTIT: expected '9170', got '%s'", record["INSTIT"])
+	if record.Get("INSTIT") != "9170" {
+		t.Errorf("INSTIT: expected '9170', got '%s'", record.Get("INSTIT"))
 	}
 
-	if record["ID"] != "917000047" {
-		t.Errorf("ID: expected '917000047', got '%s'", record["ID"])
+	if record.Get("ID") != "917000047" {
+		t.Errorf("ID: expected '917000047', got '%s'", record.Get("ID"))
 	}
 
-	if record["COURSE"] != "2102-530" {
-		t.Errorf("COURSE: expected '2102-530', got '%s'", record["COURSE"])
+	if record.Get("COURSE") != "2102-530" {
+		t.Errorf("COURSE: expected '2102-530', got '%s'", record.Get("COURSE"))
 	}
 
-	if record["CRS_SRT"] != "28092023" {
-		t.Errorf("CRS_SRT: expected '28092023', got '%s'", record["CRS_SRT"])
+	if record.Get("CRS_SRT") != "28092023" {
+		t.Errorf("CRS_SRT: expected '28092023', got '%s'", record.Get("CRS_SRT"))
 	}
 
-	if record["NSN"] != "120331711" {
-		t.Errorf("NSN: expected '120331711', got '%s'", record["NSN"])
+	if record.Get("NSN") != "120331711" {
+		t.Errorf("NSN: expected '120331711', got '%s'", record.Get("NSN"))
 	}
 
-	if record["CRS_END"] != "06062024" {
-		t.Errorf("CRS_END: expected '06062024', got '%s'", record["CRS_END"])
+	if record.Get("CRS_END") != "06062024" {
+		t.Errorf("CRS_END: expected '06062024', got '%s'", record.Get("CRS_END"))
 	}
 
-	if record["PBRF_CRS_COMP_YR"] != "" {
-		t.Errorf("PBRF_CRS_COMP_YR: expected '', got '%s'", record["PBRF_CRS_COMP_YR"])
+	if record.Get("PBRF_CRS_COMP_YR") != "" {
+		t.Errorf("PBRF_CRS_COMP_YR: expected '', got '%s'", record.Get("PBRF_CRS_COMP_YR"))
 	}
 }
 
@@ -138,13 +138,13 @@ func TestCOMPParser_ParseWithSpaces(t *testing.T) {
 	}
 
 	// Fields should be trimmed
-	if record["COURSE"] != "2102-530" {
-		t.Errorf("COURSE should be trimmed: got '%s'", record["COURSE"])
+	if record.Get("COURSE") != "2102-530" {
+		t.Errorf("COURSE should be trimmed: got '%s'", record.Get("COURSE"))
 	}
 
 	// Test that trailing spaces are properly handled
-	if record["PBRF_CRS_COMP_YR"] != "" {
-		t.Errorf("PBRF_CRS_COMP_YR should be trimmed: got '%s'", record["PBRF_CRS_COMP_YR"])
+	if record.Get("PBRF_CRS_COMP_YR") != "" {
+		t.Errorf("PBRF_CRS_COMP_YR should be trimmed: got '%s'", record.Get("PBRF_CRS_COMP_YR"))
 	}
 }
 
@@ -215,6 +215,30 @@ func TestCOMPParser_FieldBounds(t *testing.T) {
 	}
 }
 
+func TestCOMPParser_TypedFields(t *testing.T) {
+	parser := NewCOMPParser()
+	line := strings.Repeat("X", 65)
+
+	// The dates and year are not of their type; the validator reports them
+	validator := NewValidator(parser.GetSpec())
+	if err := parser.ParseReader(strings.NewReader(line), validator.Check); err != nil {
+		t.Fatalf("ParseReader failed: %v", err)
+	}
+	var fields []string
+	for _, finding := range findingsByRule(validator.Findings(), "field-type") {
+		fields = append(fields, finding.Field)
+	}
+	if strings.Join(fields, ",") != "CRS_SRT,CRS_END,PBRF_CRS_COMP_YR" {
+		t.Errorf("Expected CRS_SRT, CRS_END and PBRF_CRS_COMP_YR findings, got %v", fields)
+	}
+
+	// In strict mode the first of them stops the parse
+	parser.SetOptions(ParseOptions{Strict: true})
+	if _, err := parser.parseLine(line); err == nil || !strings.Contains(err.Error(), "field CRS_SRT") {
+		t.Errorf("Expected CRS_SRT error, got: %v", err)
+	}
+}
+
 func TestCOMPParser_RequiredFields(t *testing.T) {
 	parser := NewCOMPParser()
 
@@ -249,8 +273,8 @@ func TestCOMPParser_MultipleRecords(t *testing.T) {
 	// Verify different student IDs
 	expectedIDs := []string{"917000047", "917000440", "917000116"}
 	for i, expectedID := range expectedIDs {
-		if records[i]["ID"] != expectedID {
-			t.Errorf("Record %d: expected ID '%s', got '%s'", i, expectedID, records[i]["ID"])
+		if records[i].Get("ID") != expectedID {
+			t.Errorf("Record %d: expected ID '%s', got '%s'", i, expectedID, records[i].Get("ID"))
 		}
 	}
 }
@@ -276,16 +300,16 @@ func TestCOMPParser_RealWorldData(t *testing.T) {
 
 	// Verify all records have the same student ID
 	for i, record := range records {
-		if record["ID"] != "917000047" {
-			t.Errorf("Record %d: expected ID '917000047', got '%s'", i, record["ID"])
+		if record.Get("ID") != "917000047" {
+			t.Errorf("Record %d: expected ID '917000047', got '%s'", i, record.Get("ID"))
 		}
 	}
 
 	// Verify different course codes
 	expectedCourses := []string{"2102-530", "2102-510", "2102-520", "2102-240", "2102-516"}
 	for i, expectedCourse := range expectedCourses {
-		if records[i]["COURSE"] != expectedCourse {
-			t.Errorf("Record %d: expected COURSE '%s', got '%s'", i, expectedCourse, records[i]["COURSE"])
+		if records[i].Get("COURSE") != expectedCourse {
+			t.Errorf("Record %d: expected COURSE '%s', got '%s'", i, expectedCourse, records[i].Get("COURSE"))
 		}
 	}
 }
@@ -305,32 +329,32 @@ func TestCOMPParser_FieldExtraction(t *testing.T) {
 	}
 
 	// Verify exact field extraction
-	if record["INSTIT"] != "9170" {
-		t.Errorf("INSTIT extraction failed: got '%s'", record["INSTIT"])
+	if record.Get("INSTIT") != "9170" {
+		t.Errorf("INSTIT extraction failed: got '%s'", record.Get("INSTIT"))
 	}
 
-	if record["ID"] != "917000047" {
-		t.Errorf("ID extraction failed: got '%s'", record["ID"])
+	if record.Get("ID") != "917000047" {
+		t.Errorf("ID extraction failed: got '%s'", record.Get("ID"))
 	}
 
-	if record["COURSE"] != "2102-530" {
-		t.Errorf("COURSE extraction failed: got '%s'", record["COURSE"])
+	if record.Get("COURSE") != "2102-530" {
+		t.Errorf("COURSE extraction failed: got '%s'", record.Get("COURSE"))
 	}
 
-	if record["CRS_SRT"] != "28092023" {
-		t.Errorf("CRS_SRT extraction failed: got '%s'", record["CRS_SRT"])
+	if record.Get("CRS_SRT") != "28092023" {
+		t.Errorf("CRS_SRT extraction failed: got '%s'", record.Get("CRS_SRT"))
 	}
 
-	if record["NSN"] != "120331711" {
-		t.Errorf("NSN extraction failed: got '%s'", record["NSN"])
+	if record.Get("NSN") != "120331711" {
+		t.Errorf("NSN extraction failed: got '%s'", record.Get("NSN"))
 	}
 
-	if record["CRS_END"] != "06062024" {
-		t.Errorf("CRS_END extraction failed: got '%s'", record["CRS_END"])
+	if record.Get("CRS_END") != "06062024" {
+		t.Errorf("CRS_END extraction failed: got '%s'", record.Get("CRS_END"))
 	}
 
-	if record["PBRF_CRS_COMP_YR"] != "" {
-		t.Errorf("PBRF_CRS_COMP_YR extraction failed: got '%s'", record["PBRF_CRS_COMP_YR"])
+	if record.Get("PBRF_CRS_COMP_YR") != "" {
+		t.Errorf("PBRF_CRS_COMP_YR extraction failed: got '%s'", record.Get("PBRF_CRS_COMP_YR"))
 	}
 }
 
@@ -410,14 +434,14 @@ func TestCOMPParser_ActualDataStructure(t *testing.T) {
 	}
 
 	for fieldName, expectedValue := range expectedValues {
-		if record[fieldName] != expectedValue {
-			t.Errorf("Field %s: expected '%s', got '%s'", fieldName, expectedValue, record[fieldName])
+		if record.Get(fieldName) != expectedValue {
+			t.Errorf("Field %s: expected '%s', got '%s'", fieldName, expectedValue, record.Get(fieldName))
 		}
 	}
 
 	// Verify all fields are present
 	for _, field := range parser.GetSpec().Fields {
-		if _, exists := record[field.Name]; !exists {
+		if _, exists := record.Lookup(field.Name); !exists {
 			t.Errorf("Field %s missing from parsed record", field.Name)
 		}
 	}
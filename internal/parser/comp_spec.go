@@ -6,6 +6,7 @@ func GetCOMPSpec() FileSpec {
 		FileType:    "COMP",
 		Description: "Course Completion records",
 		LineLength:  65, // Based on official specification ending at position 65
+		Key:         []string{"ID", "COURSE", "CRS_SRT"},
 		Fields: []FieldSpec{
 			{
 				Name:     "INSTIT",
@@ -13,6 +14,7 @@ func GetCOMPSpec() FileSpec {
 				Start:    1,
 				Length:   4,
 				Required: true,
+				Type:     FieldCode,
 			},
 			{
 				Name:     "ID",
@@ -34,6 +36,8 @@ func GetCOMPSpec() FileSpec {
 				Start:    35,
 				Length:   1,
 				Required: false,
+				Type:     FieldCode,
+				CodeSet:  "COMPLETE",
 			},
 			{
 				Name:     "CRS_SRT",
@@ -41,13 +45,16 @@ func GetCOMPSpec() FileSpec {
 				Start:    36,
 				Length:   8,
 				Required: true,
+				Type:     FieldDate,
 			},
 			{
-				Name:     "NSN",
-				Title:    "National Student Number",
-				Start:    44,
-				Length:   10,
-				Required: false,
+				Name:       "NSN",
+				Title:      "National Student Number",
+				Start:      44,
+				Length:     10,
+				Required:   false,
+				Type:       FieldCode,
+				RightAlign: true,
 			},
 			{
 				Name:     "CRS_END",
@@ -55,6 +62,7 @@ func GetCOMPSpec() FileSpec {
 				Start:    54,
 				Length:   8,
 				Required: true,
+				Type:     FieldDate,
 			},
 			{
 				Name:     "PBRF_CRS_COMP_YR",
@@ -62,6 +70,7 @@ func GetCOMPSpec() FileSpec {
 				Start:    62,
 				Length:   4,
 				Required: false,
+				Type:     FieldInteger,
 			},
 		},
 	}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -23,32 +24,32 @@ func TestCourseEnrolmentParser_Parse(t *testing.T) {
 	}
 
 	// Test first record
-	if records[0]["INSTIT"] != "9170" {
-		t.Errorf("Expected INSTIT '9170', got '%s'", records[0]["INSTIT"])
+	if records[0].Get("INSTIT") != "9170" {
+		t.Errorf("Expected INSTIT '9170', got '%s'", records[0].Get("INSTIT"))
 	}
 
-	if records[0]["ID"] != "917000047" {
-		t.Errorf("Expected ID '917000047', got '%s'", records[0]["ID"])
+	if records[0].Get("ID") != "917000047" {
+		t.Errorf("Expected ID '917000047', got '%s'", records[0].Get("ID"))
 	}
 
-	if records[0]["QUAL"] != "NZ2102" {
-		t.Errorf("Expected QUAL 'NZ2102', got '%s'", records[0]["QUAL"])
+	if records[0].Get("QUAL") != "NZ2102" {
+		t.Errorf("Expected QUAL 'NZ2102', got '%s'", records[0].Get("QUAL"))
 	}
 
-	if records[0]["COURSE"] != "2102-530" {
-		t.Errorf("Expected COURSE '2102-530', got '%s'", records[0]["COURSE"])
+	if records[0].Get("COURSE") != "2102-530" {
+		t.Errorf("Expected COURSE '2102-530', got '%s'", records[0].Get("COURSE"))
 	}
 
-	if records[0]["CRS_SRT"] != "28092023" {
-		t.Errorf("Expected CRS_SRT '28092023', got '%s'", records[0]["CRS_SRT"])
+	if records[0].Get("CRS_SRT") != "28092023" {
+		t.Errorf("Expected CRS_SRT '28092023', got '%s'", records[0].Get("CRS_SRT"))
 	}
 
-	if records[0]["CRS_END"] != "06062024" {
-		t.Errorf("Expected CRS_END '06062024', got '%s'", records[0]["CRS_END"])
+	if records[0].Get("CRS_END") != "06062024" {
+		t.Errorf("Expected CRS_END '06062024', got '%s'", records[0].Get("CRS_END"))
 	}
 
-	if records[0]["NSN"] != "120331711" {
-		t.Errorf("Expected NSN '120331711', got '%s'", records[0]["NSN"])
+	if records[0].Get("NSN") != "120331711" {
+		t.Errorf("Expected NSN '120331711', got '%s'", records[0].Get("NSN"))
 	}
 }
 
@@ -84,6 +85,19 @@ func TestCourseEnrolmentParser_GetHeaders(t *testing.T) {
 		"NZSCED Field of Study",
 		"Course EFTS Factor",
 		"EFTS by Month",
+		"EFTS January",
+		"EFTS February",
+		"EFTS March",
+		"EFTS April",
+		"EFTS May",
+		"EFTS June",
+		"EFTS July",
+		"EFTS August",
+		"EFTS September",
+		"EFTS October",
+		"EFTS November",
+		"EFTS December",
+		"EFTS Total",
 		"National Student Number",
 	}
 
@@ -98,6 +112,21 @@ func TestCourseEnrolmentParser_GetHeaders(t *testing.T) {
 	}
 }
 
+func TestCourseEnrolmentParser_UsesSpecInUse(t *testing.T) {
+	saved := RegisteredFileTypes()
+	defer func() { registry = saved }()
+
+	spec := CourseEnrolmentSpec
+	spec.Fields = append([]FieldSpec{}, spec.Fields...)
+	spec.Fields[len(spec.Fields)-1].Title = "Reserved"
+	UseSpec(spec)
+
+	headers := NewCourseEnrolmentParser().GetHeaders()
+	if headers[len(headers)-1] != "Reserved" {
+		t.Errorf("Expected the overriding spec's header 'Reserved', got '%s'", headers[len(headers)-1])
+	}
+}
+
 func TestCourseEnrolmentParser_EmptyContent(t *testing.T) {
 	parser := NewCourseEnrolmentParser()
 
@@ -134,7 +163,8 @@ func TestCourseEnrolmentParser_InvalidLineLength(t *testing.T) {
 	parser := NewCourseEnrolmentParser()
 
 	// Line too long should now be handled by truncation
-	content := strings.Repeat("X", 200) // More than 186 characters
+	// (a valid 186-character line followed by 14 characters of junk)
+	content := "9170917000047 NZ21022102-530            2809202306062024        0029837NNNP122  1101090.11670.0117 0.0117 0.0117 0.0117 0.0117 0.0114 0.0000 0.0000 0.0000 0.0000 0.0000 0.0000  120331711" + strings.Repeat("X", 14)
 
 	records, err := parser.Parse(content)
 	if err != nil {
@@ -170,7 +200,7 @@ func TestCourseEnrolmentParser_RequiredFields(t *testing.T) {
 		t.Logf("Unexpectedly got %d records without required field error", len(records))
 		if len(records) > 0 {
 			// Check if the first required field is actually empty
-			if records[0]["INSTIT"] == "" {
+			if records[0].Get("INSTIT") == "" {
 				t.Error("Empty required field should have caused an error")
 			}
 		}
@@ -198,16 +228,16 @@ func TestCourseEnrolmentParser_RealWorldData(t *testing.T) {
 
 	// Verify all records have the same student ID
 	for i, record := range records {
-		if record["ID"] != "917000047" {
-			t.Errorf("Record %d: expected ID '917000047', got '%s'", i, record["ID"])
+		if record.Get("ID") != "917000047" {
+			t.Errorf("Record %d: expected ID '917000047', got '%s'", i, record.Get("ID"))
 		}
 	}
 
 	// Verify different course codes
 	expectedCourses := []string{"2102-530", "2102-510", "2102-520", "2102-240", "2102-516"}
 	for i, expectedCourse := range expectedCourses {
-		if records[i]["COURSE"] != expectedCourse {
-			t.Errorf("Record %d: expected COURSE '%s', got '%s'", i, expectedCourse, records[i]["COURSE"])
+		if records[i].Get("COURSE") != expectedCourse {
+			t.Errorf("Record %d: expected COURSE '%s', got '%s'", i, expectedCourse, records[i].Get("COURSE"))
 		}
 	}
 }
@@ -230,31 +260,69 @@ func TestCourseEnrolmentParser_FieldExtraction(t *testing.T) {
 	record := records[0]
 
 	// Verify exact field extraction
-	if record["INSTIT"] != "9170" {
-		t.Errorf("INSTIT extraction failed: got '%s'", record["INSTIT"])
+	if record.Get("INSTIT") != "9170" {
+		t.Errorf("INSTIT extraction failed: got '%s'", record.Get("INSTIT"))
+	}
+
+	if record.Get("ID") != "917000047" {
+		t.Errorf("ID extraction failed: got '%s'", record.Get("ID"))
+	}
+
+	if record.Get("QUAL") != "NZ2102" {
+		t.Errorf("QUAL extraction failed: got '%s'", record.Get("QUAL"))
+	}
+
+	if record.Get("COURSE") != "2102-530" {
+		t.Errorf("COURSE extraction failed: got '%s'", record.Get("COURSE"))
+	}
+
+	if record.Get("CRS_SRT") != "28092023" {
+		t.Errorf("CRS_SRT extraction failed: got '%s'", record.Get("CRS_SRT"))
 	}
 
-	if record["ID"] != "917000047" {
-		t.Errorf("ID extraction failed: got '%s'", record["ID"])
+	if record.Get("CRS_END") != "06062024" {
+		t.Errorf("CRS_END extraction failed: got '%s'", record.Get("CRS_END"))
 	}
 
-	if record["QUAL"] != "NZ2102" {
-		t.Errorf("QUAL extraction failed: got '%s'", record["QUAL"])
+	if record.Get("NSN") != "120331711" {
+		t.Errorf("NSN extraction failed: got '%s'", record.Get("NSN"))
 	}
+}
+
+func TestCourseEnrolmentParser_MonthlyEFTS(t *testing.T) {
+	parser := NewCourseEnrolmentParser()
 
-	if record["COURSE"] != "2102-530" {
-		t.Errorf("COURSE extraction failed: got '%s'", record["COURSE"])
+	content := "9170917000047 NZ21022102-530            2809202306062024        0029837NNNP122  1101090.11670.0117 0.0117 0.0117 0.0117 0.0117 0.0114 0.0000 0.0000 0.0000 0.0000 0.0000 0.0000  120331711"
+
+	records, err := parser.Parse(content)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	record := records[0]
+
+	expectedMonths := []string{"0.0117", "0.0117", "0.0117", "0.0117", "0.0117", "0.0114",
+		"0.0000", "0.0000", "0.0000", "0.0000", "0.0000", "0.0000"}
+	for i, expected := range expectedMonths {
+		name := fmt.Sprintf("EFTS_MTH_%02d", i+1)
+		if record.Get(name) != expected {
+			t.Errorf("%s: expected '%s', got '%s'", name, expected, record.Get(name))
+		}
 	}
 
-	if record["CRS_SRT"] != "28092023" {
-		t.Errorf("CRS_SRT extraction failed: got '%s'", record["CRS_SRT"])
+	if record.Get("EFTS_MTH_TOTAL") != "0.0699" {
+		t.Errorf("Expected EFTS_MTH_TOTAL '0.0699', got '%s'", record.Get("EFTS_MTH_TOTAL"))
 	}
 
-	if record["CRS_END"] != "06062024" {
-		t.Errorf("CRS_END extraction failed: got '%s'", record["CRS_END"])
+	// The packed field is kept alongside its monthly breakdown
+	if !strings.HasPrefix(record.Get("EFTS_MTH"), "0.0117 0.0117") {
+		t.Errorf("Expected packed EFTS_MTH to be kept, got '%s'", record.Get("EFTS_MTH"))
 	}
 
-	if record["NSN"] != "120331711" {
-		t.Errorf("NSN extraction failed: got '%s'", record["NSN"])
+	// A non-numeric month is a type error in strict mode
+	bad := strings.Replace(content, "0.0114", "0.01X4", 1)
+	parser.SetOptions(ParseOptions{Strict: true})
+	if _, err := parser.Parse(bad); err == nil || !strings.Contains(err.Error(), "EFTS_MTH_06") {
+		t.Errorf("Expected EFTS_MTH_06 error, got: %v", err)
 	}
 }
@@ -45,7 +45,7 @@ func TestCREGParser_Parse(t *testing.T) {
 	}
 
 	for fieldName, expectedValue := range expectedFields {
-		if actualValue, exists := firstRecord[fieldName]; !exists {
+		if actualValue, exists := firstRecord.Lookup(fieldName); !exists {
 			t.Errorf("Field %s missing from record", fieldName)
 		} else if actualValue != expectedValue {
 			t.Errorf("Field %s: expected '%s', got '%s'", fieldName, expectedValue, actualValue)
@@ -86,7 +86,7 @@ func TestCREGParser_ParseSingleLine(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		if actual, exists := record[test.fieldName]; !exists {
+		if actual, exists := record.Lookup(test.fieldName); !exists {
 			t.Errorf("Field %s missing from record", test.fieldName)
 		} else if actual != test.expected {
 			t.Errorf("Field %s: expected '%s', got '%s'", test.fieldName, test.expected, actual)
@@ -182,7 +182,7 @@ func TestCREGParser_LongCourseTitles(t *testing.T) {
 	}
 
 	expectedTitle := "Prepare, cook and finish rice, grain, farinaceous products and egg dishes"
-	if actualTitle := record["CTITLE"]; actualTitle != expectedTitle {
+	if actualTitle := record.Get("CTITLE"); actualTitle != expectedTitle {
 		t.Errorf("Course title: expected '%s', got '%s'", expectedTitle, actualTitle)
 	}
 }
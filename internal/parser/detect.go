@@ -0,0 +1,241 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sniffLines is the number of non-blank lines sampled when sniffing a file's content
+const sniffLines = 20
+
+// minConfidence is the lowest content score accepted as a detection
+const minConfidence = 0.75
+
+// minMargin is how far the best content score must be ahead of the runner-up
+const minMargin = 0.1
+
+// Detection is the result of working out which SDR file type a file holds
+type Detection struct {
+	FileType   string           // Detected file type, empty if unknown
+	Confidence float64          // Score of the detected type, 0 to 1
+	ByContent  bool             // Whether the type came from the content rather than the filename
+	Scores     []DetectionScore // Content score of every registered type, best first
+}
+
+// DetectionScore is how well a file's sampled lines fit one registered spec
+type DetectionScore struct {
+	FileType string
+	Score    float64
+}
+
+// DetectFile determines the file type of an SDR file from its content, using the
+// filename hints only when the content is inconclusive
+func DetectFile(path string) (Detection, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Detection{}, fmt.Errorf("failed to read input file: %w", err)
+	}
+	defer file.Close()
+
+	detection, err := SniffFileType(file)
+	if err != nil {
+		return Detection{}, err
+	}
+	if detection.FileType != "" {
+		return detection, nil
+	}
+
+	// Content is inconclusive, fall back to the filename hints
+	if fileType := DetectFileType(filepath.Base(path)); fileType != "" {
+		detection.FileType = fileType
+		detection.Confidence = scoreOf(detection.Scores, fileType)
+	}
+	return detection, nil
+}
+
+// SniffFileType scores the first lines of the content against every registered spec.
+// FileType is empty if no spec scores at least minConfidence, or if the best two are too close to call.
+func SniffFileType(r io.Reader) (Detection, error) {
+	var lines []string
+
+	decoded, _, _, err := decodeInput(r, EncodingAuto)
+	if err != nil {
+		return Detection{}, fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	scanner := bufio.NewScanner(decoded)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineBytes)
+	scanner.Split(scanLines)
+	for len(lines) < sniffLines && scanner.Scan() {
+		if line := scanner.Text(); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Detection{}, fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	detection := Detection{ByContent: true}
+	for _, reg := range registry {
+		// A file may use any of the type's layouts, so the best fitting one counts
+		best := 0.0
+		for _, spec := range reg.Specs() {
+			best = max(best, scoreLines(spec, lines))
+		}
+		detection.Scores = append(detection.Scores, DetectionScore{
+			FileType: reg.Spec.FileType,
+			Score:    best,
+		})
+	}
+	// Stable sort keeps registration order between equal scores
+	sort.SliceStable(detection.Scores, func(i, j int) bool {
+		return detection.Scores[i].Score > detection.Scores[j].Score
+	})
+
+	if isConclusive(detection.Scores) {
+		detection.FileType = detection.Scores[0].FileType
+		detection.Confidence = detection.Scores[0].Score
+	} else {
+		detection.ByContent = false
+	}
+
+	return detection, nil
+}
+
+// isConclusive reports whether the best score is high enough and clearly ahead of the runner-up
+func isConclusive(scores []DetectionScore) bool {
+	if len(scores) == 0 || scores[0].Score < minConfidence {
+		return false
+	}
+	return len(scores) == 1 || scores[0].Score-scores[1].Score >= minMargin
+}
+
+// scoreLines returns the average line score of the sampled lines
+func scoreLines(spec FileSpec, lines []string) float64 {
+	if len(lines) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, line := range lines {
+		total += scoreLine(spec, line)
+	}
+	return total / float64(len(lines))
+}
+
+// scoreLine scores how well a single line fits a spec, from 0 to 1.
+// Line length counts for 40% and the shape of the fields for 60%.
+func scoreLine(spec FileSpec, line string) float64 {
+	return 0.4*lengthScore(spec, line) + 0.6*shapeScore(spec, line)
+}
+
+// lengthScore compares the line length with the spec's line length.
+// Trailing blanks are often dropped, so a shorter line scores in proportion to its length.
+func lengthScore(spec FileSpec, line string) float64 {
+	if spec.LineLength == 0 {
+		return 0
+	}
+	length := charCount(line)
+	if length == spec.LineLength {
+		return 1
+	}
+	trimmed := charCount(strings.TrimRight(line, " "))
+	if trimmed > spec.LineLength {
+		return 0 // Data beyond the end of the layout
+	}
+	if length > spec.LineLength {
+		return 0.9 // Only blank padding beyond the end of the layout
+	}
+	return float64(trimmed) / float64(spec.LineLength)
+}
+
+// shapeScore returns the fraction of informative field checks the line passes:
+// a numeric INSTIT, required fields present and typed values valid
+func shapeScore(spec FileSpec, line string) float64 {
+	padded := line
+	if length := charCount(padded); length < spec.LineLength {
+		padded += strings.Repeat(" ", spec.LineLength-length)
+	}
+	paddedLength := charCount(padded)
+
+	checks, passed := 0, 0
+	for _, field := range spec.Fields {
+		start := field.Start - 1
+		end := start + field.Length
+		if start < 0 || end > paddedLength {
+			continue
+		}
+		value := strings.TrimSpace(sliceChars(padded, start, end))
+
+		if field.Name == "INSTIT" {
+			checks++
+			if len(value) == field.Length && isNumeric(value) {
+				passed++
+			}
+			continue
+		}
+		if field.Required {
+			checks++
+			if value != "" {
+				passed++
+			}
+		}
+		if field.Type != FieldText && value != "" {
+			checks++
+			if field.Check(value) == nil {
+				passed++
+			}
+		}
+	}
+
+	if checks == 0 {
+		return 0
+	}
+	return float64(passed) / float64(checks)
+}
+
+// scoreOf returns the score of a file type in a list of scores
+func scoreOf(scores []DetectionScore, fileType string) float64 {
+	for _, score := range scores {
+		if score.FileType == fileType {
+			return score.Score
+		}
+	}
+	return 0
+}
+
+// FindSDRFiles finds the SDR files in a directory by their content, so renamed files are
+// still found, grouped by file type in registration order. An empty fileType finds every
+// type. The _rebuilt.txt files written by RebuildFile are copies of other files and are skipped.
+func FindSDRFiles(dir, fileType string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	byType := make(map[string][]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(strings.ToLower(name), ".txt") || IsRebuiltFile(name) {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		detection, err := DetectFile(path)
+		if err == nil {
+			byType[detection.FileType] = append(byType[detection.FileType], path)
+		}
+	}
+
+	var files []string
+	for _, reg := range RegisteredFileTypes() {
+		if fileType == "" || strings.EqualFold(fileType, reg.Spec.FileType) {
+			files = append(files, byType[reg.Spec.FileType]...)
+		}
+	}
+	return files, nil
+}
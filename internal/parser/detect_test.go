@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Sample content of each built-in file type (FAKE DATA, based on the parser tests)
+var sniffSamples = map[string]string{
+	"STUD": sampleSTUDData,
+	"COUR": "9170917000047 NZ21022102-530            2809202306062024        0029837NNNP122  1101090.11670.0117 0.0117 0.0117 0.0117 0.0117 0.0114 0.0000 0.0000 0.0000 0.0000 0.0000 0.0000  120331711",
+	"CREG": sampleCREGData,
+	"COMP": "9170917000047 2102-530            028092023 12033171106062024    \n9170917000440 2102-530            027022024 16264822205112024    ",
+	"QUAL": "9170917000478  140261767NZ2101            2024    \n9170917000281   98180910NZ2101            2024    ",
+}
+
+func TestSniffFileType(t *testing.T) {
+	for expected, content := range sniffSamples {
+		detection, err := SniffFileType(strings.NewReader(content))
+		if err != nil {
+			t.Fatalf("SniffFileType failed: %v", err)
+		}
+
+		if detection.FileType != expected {
+			t.Errorf("Expected %s, got '%s' (scores: %v)", expected, detection.FileType, detection.Scores)
+		}
+		if !detection.ByContent || detection.Confidence < minConfidence {
+			t.Errorf("%s: expected confident content detection, got %+v", expected, detection)
+		}
+	}
+}
+
+func TestSniffFileType_MissingTrailingBlanks(t *testing.T) {
+	// Editors often strip trailing blanks; the layout should still be recognised
+	content := "9170917000478  140261767NZ2101            2024\n9170917000441  171046090NZ2101            2024"
+
+	detection, err := SniffFileType(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("SniffFileType failed: %v", err)
+	}
+	if detection.FileType != "QUAL" {
+		t.Errorf("Expected QUAL, got '%s' (scores: %v)", detection.FileType, detection.Scores)
+	}
+}
+
+func TestDetectFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		filename  string
+		content   string
+		expected  string
+		byContent bool
+	}{
+		// Renamed file is recognised by its content
+		{"export_2024.txt", sniffSamples["QUAL"], "QUAL", true},
+		// Content wins over a misleading filename
+		{"COURSE_COMPLETIONS.txt", sniffSamples["COMP"], "COMP", true},
+		// Unrecognisable content falls back to the filename
+		{"STUD_notes.txt", "not an SDR file", "STUD", false},
+		// Neither content nor filename identify the file
+		{"notes.txt", "not an SDR file", "", false},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(dir, test.filename)
+		if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", test.filename, err)
+		}
+
+		detection, err := DetectFile(path)
+		if err != nil {
+			t.Fatalf("DetectFile(%s) failed: %v", test.filename, err)
+		}
+		if detection.FileType != test.expected || detection.ByContent != test.byContent {
+			t.Errorf("%s: expected %s (by content: %v), got %s (by content: %v)",
+				test.filename, test.expected, test.byContent, detection.FileType, detection.ByContent)
+		}
+	}
+}
+
+func TestProcessFile_RenamedFile(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "export_2024.txt")
+	if err := os.WriteFile(inputPath, []byte(sniffSamples["COMP"]), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	result := ProcessFile(inputPath, dir)
+	if !result.Success {
+		t.Fatalf("Expected success, got: %v", result.Error)
+	}
+	if result.FileType != "COMP" || result.RecordCount != 2 {
+		t.Errorf("Expected 2 COMP records, got %d %s records", result.RecordCount, result.FileType)
+	}
+	if result.Confidence < minConfidence {
+		t.Errorf("Expected confidence of at least %v, got %v", minConfidence, result.Confidence)
+	}
+}
+
+func TestFindSDRFiles_SkipsRebuiltFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"COUR9170.txt": sniffSamples["COUR"], "STUD9170.txt": sampleSTUDData} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	// Parse and rebuild COUR, leaving COUR9170_rebuilt.txt next to the input
+	parsed := ProcessFile(filepath.Join(dir, "COUR9170.txt"), dir)
+	if !parsed.Success {
+		t.Fatalf("ProcessFile failed: %v", parsed.Error)
+	}
+	if rebuilt := RebuildFile(parsed.OutputFile, dir, ProcessOptions{}); !rebuilt.Success || !IsRebuiltFile(rebuilt.OutputFile) {
+		t.Fatalf("RebuildFile failed: %v", rebuilt.Error)
+	}
+
+	files, err := FindSDRFiles(dir, "")
+	if err != nil {
+		t.Fatalf("FindSDRFiles failed: %v", err)
+	}
+	if len(files) != 2 || filepath.Base(files[0]) != "STUD9170.txt" || filepath.Base(files[1]) != "COUR9170.txt" {
+		t.Fatalf("Expected STUD9170.txt and COUR9170.txt, got %v", files)
+	}
+	if cour, _ := FindSDRFiles(dir, "cour"); len(cour) != 1 {
+		t.Errorf("Expected one COUR file, got %v", cour)
+	}
+
+	if result := ValidateReturn(files, ProcessOptions{}); result.Error != nil {
+		t.Errorf("Expected the return to load, got %v", result.Error)
+	}
+}
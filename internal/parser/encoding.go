@@ -0,0 +1,202 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Encoding is the character encoding of an SDR file
+type Encoding string
+
+const (
+	EncodingAuto        Encoding = ""             // Detect: UTF-8 (with or without BOM), otherwise Windows-1252
+	EncodingUTF8        Encoding = "utf-8"        // UTF-8; a leading byte order mark is skipped
+	EncodingWindows1252 Encoding = "windows-1252" // Windows code page 1252
+	EncodingLatin1      Encoding = "latin-1"      // ISO-8859-1
+)
+
+// sniffBytes is how much of a file is checked when detecting its encoding
+const sniffBytes = 64 * 1024
+
+// utf8BOM is the UTF-8 byte order mark written by some Windows tools
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// ParseEncoding returns the encoding with the given name, accepting common aliases
+func ParseEncoding(name string) (Encoding, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "auto":
+		return EncodingAuto, nil
+	case "utf-8", "utf8":
+		return EncodingUTF8, nil
+	case "windows-1252", "cp1252", "win1252":
+		return EncodingWindows1252, nil
+	case "latin-1", "latin1", "iso-8859-1":
+		return EncodingLatin1, nil
+	}
+	return EncodingAuto, fmt.Errorf("unknown encoding %q (expected auto, utf-8, windows-1252 or latin-1)", name)
+}
+
+// String returns the name of the encoding
+func (e Encoding) String() string {
+	if e == EncodingAuto {
+		return "auto"
+	}
+	return string(e)
+}
+
+// singleByte reports whether every character of the encoding is one byte
+func (e Encoding) singleByte() bool {
+	return e.charmap() != nil
+}
+
+// charmap returns the table of a single-byte encoding, or nil for UTF-8
+func (e Encoding) charmap() *charmap.Charmap {
+	switch e {
+	case EncodingWindows1252:
+		return charmap.Windows1252
+	case EncodingLatin1:
+		return charmap.ISO8859_1
+	}
+	return nil
+}
+
+// decodeInput wraps r so it yields UTF-8. It returns the encoding in use, with
+// EncodingAuto resolved, and the number of bytes of byte order mark skipped.
+func decodeInput(r io.Reader, enc Encoding) (io.Reader, Encoding, int, error) {
+	br := bufio.NewReaderSize(r, sniffBytes)
+
+	bomLength := 0
+	if enc == EncodingAuto || enc == EncodingUTF8 {
+		head, err := br.Peek(len(utf8BOM))
+		if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, enc, 0, fmt.Errorf("failed to read input: %w", err)
+		}
+		if bytes.Equal(head, utf8BOM) {
+			br.Discard(len(utf8BOM))
+			bomLength = len(utf8BOM)
+			enc = EncodingUTF8
+		}
+	}
+
+	if enc == EncodingAuto {
+		head, err := br.Peek(sniffBytes)
+		if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, enc, 0, fmt.Errorf("failed to read input: %w", err)
+		}
+		enc = EncodingWindows1252
+		if validUTF8Prefix(head, err == io.EOF) {
+			enc = EncodingUTF8
+		}
+	}
+
+	if enc == EncodingUTF8 {
+		return br, enc, bomLength, nil
+	}
+	if table := enc.charmap(); table != nil {
+		return table.NewDecoder().Reader(br), enc, 0, nil
+	}
+	return nil, enc, 0, fmt.Errorf("unsupported encoding %s", enc)
+}
+
+// validUTF8Prefix reports whether data is valid UTF-8, allowing a character cut
+// short at the end when data is only the start of the input
+func validUTF8Prefix(data []byte, complete bool) bool {
+	if !complete {
+		// Drop a partial character at the end of the sample
+		for i := 1; i <= utf8.UTFMax && i <= len(data); i++ {
+			if utf8.RuneStart(data[len(data)-i]) {
+				if !utf8.FullRune(data[len(data)-i:]) {
+					data = data[:len(data)-i]
+				}
+				break
+			}
+		}
+	}
+	return utf8.Valid(data)
+}
+
+// encodeString converts UTF-8 text to the encoding, for writing SDR files
+func encodeString(s string, enc Encoding) ([]byte, error) {
+	table := enc.charmap()
+	if table == nil {
+		return []byte(s), nil
+	}
+
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		b, ok := table.EncodeRune(r)
+		if !ok {
+			return nil, fmt.Errorf("character %q cannot be written in %s", r, enc)
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// charCount returns the number of characters in s, which the SDR spec positions count
+func charCount(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// sliceChars returns characters start to end (0-based, end exclusive) of s.
+// Out of range positions are clamped.
+func sliceChars(s string, start, end int) string {
+	if isASCII(s) {
+		start, end = min(start, len(s)), min(end, len(s))
+		return s[start:end]
+	}
+
+	runes := []rune(s)
+	start, end = min(start, len(runes)), min(end, len(runes))
+	return string(runes[start:end])
+}
+
+// charLine is a line positioned by character. Lines with multi-byte characters
+// are converted to runes once so slicing fields does not rescan the line.
+type charLine struct {
+	text  string
+	runes []rune // Set only when text has multi-byte characters
+}
+
+// newCharLine prepares a line for slicing by character position
+func newCharLine(s string) charLine {
+	if isASCII(s) {
+		return charLine{text: s}
+	}
+	return charLine{text: s, runes: []rune(s)}
+}
+
+// length returns the number of characters in the line
+func (l charLine) length() int {
+	if l.runes != nil {
+		return len(l.runes)
+	}
+	return len(l.text)
+}
+
+// slice returns characters start to end (0-based, end exclusive), clamped to the line
+func (l charLine) slice(start, end int) string {
+	if l.runes != nil {
+		start, end = min(start, len(l.runes)), min(end, len(l.runes))
+		return string(l.runes[start:end])
+	}
+	start, end = min(start, len(l.text)), min(end, len(l.text))
+	return l.text[start:end]
+}
+
+// isASCII reports whether s holds only single-byte characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// cregLineWithTitle returns the sample CREG line with a different course title
+func cregLineWithTitle(title string) string {
+	line := strings.Split(sampleCREGData, "\n")[0]
+	return line[:24] + fmt.Sprintf("%-75s", title) + line[99:]
+}
+
+func TestFixedWidthParser_UTF8Macrons(t *testing.T) {
+	parser := NewCREGParser()
+	content := string(utf8BOM) + cregLineWithTitle("Te Reo Māori") + "\n" + cregLineWithTitle("Te Reo Maori") + "\n"
+
+	var sources []Source
+	var records []Record
+	err := parser.ParseReader(strings.NewReader(content), func(record Record, src Source) error {
+		records = append(records, record)
+		sources = append(sources, src)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ParseReader failed: %v", err)
+	}
+
+	// The BOM and the two-byte macron leave every field in place
+	if records[0].Get("INSTIT") != "9170" || records[0].Get("CTITLE") != "Te Reo Māori" || records[0].Get("QUAL") != "NZ2102" {
+		t.Errorf("Unexpected fields: INSTIT=%q CTITLE=%q QUAL=%q", records[0].Get("INSTIT"), records[0].Get("CTITLE"), records[0].Get("QUAL"))
+	}
+	if records[0].Get("FEE") != records[1].Get("FEE") {
+		t.Errorf("Expected FEE to match the line without a macron, got %q and %q", records[0].Get("FEE"), records[1].Get("FEE"))
+	}
+
+	if parser.GetEncoding() != EncodingUTF8 {
+		t.Errorf("Expected UTF-8, got %s", parser.GetEncoding())
+	}
+
+	// Offsets are positions in the file: after the BOM, and counting the macron's two bytes
+	if sources[0].Offset != 3 || sources[1].Offset != 3+149+1 {
+		t.Errorf("Unexpected offsets %d and %d", sources[0].Offset, sources[1].Offset)
+	}
+
+	warnings := parser.GetLineWarnings()
+	if len(warnings) != 1 || warnings[0].Line != 1 || !strings.Contains(warnings[0].Reason, "149 bytes but 148 characters") {
+		t.Errorf("Expected one byte/character length warning for line 1, got %+v", warnings)
+	}
+}
+
+func TestFixedWidthParser_Windows1252(t *testing.T) {
+	// "Café Owners’ Management" with é (0xE9) and a curly apostrophe (0x92) as single bytes
+	line := cregLineWithTitle("Cafe Owners' Management")
+	line = strings.Replace(line, "Cafe Owners'", "Caf\xe9 Owners\x92", 1)
+
+	for _, enc := range []Encoding{EncodingAuto, EncodingWindows1252} {
+		parser := NewCREGParser()
+		parser.SetOptions(ParseOptions{Encoding: enc})
+
+		records, err := parser.Parse(line + "\n" + line)
+		if err != nil {
+			t.Fatalf("%s: Parse failed: %v", enc, err)
+		}
+		if records[0].Get("CTITLE") != "Café Owners’ Management" || records[0].Get("QUAL") != "NZ2102" {
+			t.Errorf("%s: unexpected fields: CTITLE=%q QUAL=%q", enc, records[0].Get("CTITLE"), records[0].Get("QUAL"))
+		}
+		if parser.GetEncoding() != EncodingWindows1252 {
+			t.Errorf("%s: expected windows-1252, got %s", enc, parser.GetEncoding())
+		}
+		if len(parser.GetLineWarnings()) != 0 {
+			t.Errorf("%s: expected no warnings for a single-byte encoding", enc)
+		}
+	}
+
+	// Latin-1 has no curly apostrophe, so 0x92 stays a control character
+	parser := NewCREGParser()
+	parser.SetOptions(ParseOptions{Encoding: EncodingLatin1})
+	records, err := parser.Parse(line)
+	if err != nil {
+		t.Fatalf("latin-1: Parse failed: %v", err)
+	}
+	if records[0].Get("CTITLE") != "Café Owners\u0092 Management" {
+		t.Errorf("latin-1: unexpected CTITLE %q", records[0].Get("CTITLE"))
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	tests := map[string]Encoding{
+		"":           EncodingAuto,
+		"auto":       EncodingAuto,
+		"UTF8":       EncodingUTF8,
+		"cp1252":     EncodingWindows1252,
+		"ISO-8859-1": EncodingLatin1,
+	}
+	for name, expected := range tests {
+		if enc, err := ParseEncoding(name); err != nil || enc != expected {
+			t.Errorf("ParseEncoding(%q): expected %s, got %s (%v)", name, expected, enc, err)
+		}
+	}
+
+	if _, err := ParseEncoding("ebcdic"); err == nil {
+		t.Error("Expected error for unknown encoding")
+	}
+}
+
+func TestFixedWidthWriter_Encoding(t *testing.T) {
+	csvContent := "CODE,NAME,FLAG\n1234,Café,Y\n5678,Māori,N\n"
+
+	var out bytes.Buffer
+	writer := NewFixedWidthWriter(testSpec)
+	writer.SetEncoding(EncodingWindows1252)
+	count, err := writer.WriteFromCSV(strings.NewReader(csvContent), &out)
+	if err == nil {
+		t.Fatal("Expected error for a macron in Windows-1252")
+	}
+
+	// The first row fits in one byte per character; the macron row is reported
+	if count != 1 || out.String() != "1234Caf\xe9 Y\n" {
+		t.Errorf("Unexpected output %q (%d lines)", out.String(), count)
+	}
+	lineErrors := writer.GetLineErrors()
+	if len(lineErrors) != 1 || lineErrors[0].Line != 3 || !strings.Contains(lineErrors[0].Reason, "cannot be written in windows-1252") {
+		t.Errorf("Unexpected line errors: %+v", lineErrors)
+	}
+}
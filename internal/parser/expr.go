@@ -0,0 +1,533 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Rule expressions are small boolean expressions over the fields of a record and
+// the records joined to it:
+//
+//	FUNDING == "01" && RESIDENCY != "Y"
+//	STUD.CITIZEN not in ["NZL", "AUS"] || empty(ASSIST)
+//	FACTOR > 0 and date(CRS_SRT) >= date("01012024")
+//
+// A bare field name refers to the rule's own file type and FILE.FIELD to a joined one.
+// Values are text; == and != compare text unless one side is a number literal,
+// and the ordering operators compare numbers when both sides are numbers.
+
+// exprType is the static type of an expression
+type exprType int
+
+const (
+	typeText exprType = iota
+	typeBool
+)
+
+func (t exprType) String() string {
+	if t == typeBool {
+		return "true/false"
+	}
+	return "text"
+}
+
+// exprValues looks up a field of the rule's record or of a record joined to it
+type exprValues func(fileType, name string) string
+
+// exprNode is a compiled expression
+type exprNode interface {
+	typ() exprType
+	eval(values exprValues) any // string for typeText, bool for typeBool
+}
+
+// fieldRef is a field of the rule's record or of a joined record
+type fieldRef struct {
+	fileType string
+	name     string
+}
+
+func (f fieldRef) typ() exprType              { return typeText }
+func (f fieldRef) eval(values exprValues) any { return values(f.fileType, f.name) }
+
+// literal is a quoted text or a number
+type literal struct {
+	value  string
+	number bool
+}
+
+func (l literal) typ() exprType       { return typeText }
+func (l literal) eval(exprValues) any { return l.value }
+
+// notNode negates a condition
+type notNode struct {
+	operand exprNode
+}
+
+func (n notNode) typ() exprType              { return typeBool }
+func (n notNode) eval(values exprValues) any { return !n.operand.eval(values).(bool) }
+
+// logicalNode joins two conditions with "&&" or "||", evaluating the right only when needed
+type logicalNode struct {
+	and         bool
+	left, right exprNode
+}
+
+func (l logicalNode) typ() exprType { return typeBool }
+
+func (l logicalNode) eval(values exprValues) any {
+	left := l.left.eval(values).(bool)
+	if left != l.and {
+		return left // false && ..., true || ...
+	}
+	return l.right.eval(values).(bool)
+}
+
+// compareNode compares two texts, as numbers where the operator calls for it
+type compareNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (c compareNode) typ() exprType { return typeBool }
+
+func (c compareNode) eval(values exprValues) any {
+	left, right := c.left.eval(values).(string), c.right.eval(values).(string)
+
+	equality := c.op == "==" || c.op == "!="
+	if !equality || isNumberLiteral(c.left) || isNumberLiteral(c.right) {
+		l, lerr := strconv.ParseFloat(left, 64)
+		r, rerr := strconv.ParseFloat(right, 64)
+		if lerr == nil && rerr == nil {
+			return compareOrdered(c.op, l, r)
+		}
+	}
+	return compareOrdered(c.op, left, right)
+}
+
+// compareOrdered applies a comparison operator
+func compareOrdered[T float64 | string](op string, left, right T) bool {
+	switch op {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	default:
+		return left >= right
+	}
+}
+
+func isNumberLiteral(node exprNode) bool {
+	l, ok := node.(literal)
+	return ok && l.number
+}
+
+// inNode tests a text against a list
+type inNode struct {
+	value  exprNode
+	list   []exprNode
+	negate bool
+}
+
+func (i inNode) typ() exprType { return typeBool }
+
+func (i inNode) eval(values exprValues) any {
+	value := i.value.eval(values).(string)
+	for _, item := range i.list {
+		if item.eval(values).(string) == value {
+			return !i.negate
+		}
+	}
+	return i.negate
+}
+
+// callNode calls one of exprFunctions
+type callNode struct {
+	name string
+	args []exprNode
+}
+
+func (c callNode) typ() exprType { return exprFunctions[c.name].result }
+
+func (c callNode) eval(values exprValues) any {
+	args := make([]string, len(c.args))
+	for i, arg := range c.args {
+		args[i] = arg.eval(values).(string)
+	}
+	return exprFunctions[c.name].eval(args)
+}
+
+// exprFunction is a function rule expressions can call; its arguments are text
+type exprFunction struct {
+	args   int
+	result exprType
+	eval   func(args []string) any
+}
+
+// exprFunctions are the functions available to rule expressions
+var exprFunctions = map[string]exprFunction{
+	// empty(X) is true if X is blank
+	"empty": {1, typeBool, func(args []string) any { return args[0] == "" }},
+	// len(X) is the number of characters in X
+	"len": {1, typeText, func(args []string) any { return strconv.Itoa(charCount(args[0])) }},
+	// starts_with(X, P) is true if X begins with P
+	"starts_with": {2, typeBool, func(args []string) any { return strings.HasPrefix(args[0], args[1]) }},
+	// date(X) turns a DDMMYYYY date into YYYYMMDD, so dates compare in order; "" if X is not a date
+	"date": {1, typeText, func(args []string) any {
+		date, err := FieldSpec{}.Date(args[0])
+		if err != nil {
+			return ""
+		}
+		return date.Format("20060102")
+	}},
+}
+
+// exprScope resolves the field names an expression may use
+type exprScope struct {
+	fileType string              // File type of bare field names
+	fields   map[string][]string // File type -> its column names
+}
+
+// resolve returns the reference for a field name, qualified or not
+func (s exprScope) resolve(name string) (fieldRef, error) {
+	name = strings.ToUpper(name)
+	ref := fieldRef{fileType: s.fileType, name: name}
+	if qualifier, field, ok := strings.Cut(name, "."); ok {
+		ref = fieldRef{fileType: qualifier, name: field}
+	}
+
+	columns, ok := s.fields[ref.fileType]
+	if !ok {
+		return ref, fmt.Errorf("%s is not the rule's file type or a joined one", ref.fileType)
+	}
+	for _, column := range columns {
+		if column == ref.name {
+			return ref, nil
+		}
+	}
+	return ref, fmt.Errorf("%s has no field %s", ref.fileType, ref.name)
+}
+
+// compileCondition compiles an expression that must be true or false
+func compileCondition(source string, scope exprScope) (exprNode, error) {
+	p := &exprParser{scope: scope}
+	if err := p.tokenize(source); err != nil {
+		return nil, err
+	}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEnd {
+		return nil, p.errorAt(tok, "unexpected %q", tok.text)
+	}
+	if node.typ() != typeBool {
+		return nil, fmt.Errorf("expression is %s, not a condition (did you mean to compare it?)", node.typ())
+	}
+	return node, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenName
+	tokenText
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int // 1-based character position in the expression
+}
+
+// exprSymbols are the operators and punctuation, longest first
+var exprSymbols = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "=", "!", "(", ")", "[", "]", ","}
+
+// exprParser parses an expression by recursive descent
+type exprParser struct {
+	scope  exprScope
+	tokens []token
+	next   int
+}
+
+func (p *exprParser) tokenize(source string) error {
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			var text strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				text.WriteRune(runes[j])
+			}
+			if j == len(runes) {
+				return fmt.Errorf("position %d: text is not closed with %c", pos, r)
+			}
+			p.tokens = append(p.tokens, token{tokenText, text.String(), pos})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			number := string(runes[i:j])
+			if _, err := strconv.ParseFloat(number, 64); err != nil {
+				return fmt.Errorf("position %d: invalid number %s", pos, number)
+			}
+			p.tokens = append(p.tokens, token{tokenNumber, number, pos})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '.') {
+				j++
+			}
+			p.tokens = append(p.tokens, token{tokenName, string(runes[i:j]), pos})
+			i = j
+		default:
+			symbol := ""
+			for _, s := range exprSymbols {
+				if strings.HasPrefix(string(runes[i:]), s) {
+					symbol = s
+					break
+				}
+			}
+			if symbol == "" {
+				return fmt.Errorf("position %d: unexpected character %q", pos, r)
+			}
+			i += len(symbol)
+			if symbol == "=" {
+				symbol = "==" // A common slip for ==, and never ambiguous
+			}
+			p.tokens = append(p.tokens, token{tokenSymbol, symbol, pos})
+		}
+	}
+	p.tokens = append(p.tokens, token{kind: tokenEnd, pos: len(runes) + 1})
+	return nil
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *exprParser) take() token {
+	tok := p.tokens[p.next]
+	if tok.kind != tokenEnd {
+		p.next++
+	}
+	return tok
+}
+
+// isKeyword reports whether the token is the symbol or keyword (case-insensitive)
+func isKeyword(tok token, symbol, keyword string) bool {
+	if tok.kind == tokenSymbol {
+		return tok.text == symbol
+	}
+	return tok.kind == tokenName && keyword != "" && strings.EqualFold(tok.text, keyword)
+}
+
+func (p *exprParser) errorAt(tok token, format string, args ...any) error {
+	if tok.kind == tokenEnd {
+		return fmt.Errorf("position %d: %s (at the end)", tok.pos, fmt.Sprintf(format, args...))
+	}
+	return fmt.Errorf("position %d: %s", tok.pos, fmt.Sprintf(format, args...))
+}
+
+// parseOr parses conditions joined by "||" or "or"
+func (p *exprParser) parseOr() (exprNode, error) {
+	return p.parseLogical(false, "||", "or", p.parseAnd)
+}
+
+// parseAnd parses conditions joined by "&&" or "and"
+func (p *exprParser) parseAnd() (exprNode, error) {
+	return p.parseLogical(true, "&&", "and", p.parseNot)
+}
+
+func (p *exprParser) parseLogical(and bool, symbol, keyword string, operand func() (exprNode, error)) (exprNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for isKeyword(p.peek(), symbol, keyword) {
+		op := p.take()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ() != typeBool || right.typ() != typeBool {
+			return nil, p.errorAt(op, "%s needs a condition on each side", op.text)
+		}
+		left = logicalNode{and: and, left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseNot parses a condition negated by "!" or "not"
+func (p *exprParser) parseNot() (exprNode, error) {
+	if !isKeyword(p.peek(), "!", "not") {
+		return p.parseComparison()
+	}
+	op := p.take()
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	if operand.typ() != typeBool {
+		return nil, p.errorAt(op, "%s needs a condition", op.text)
+	}
+	return notNode{operand}, nil
+}
+
+// parseComparison parses a comparison, an "in" test or a single operand
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	switch {
+	case tok.kind == tokenSymbol && (tok.text == "==" || tok.text == "!=" || tok.text == "<" || tok.text == "<=" || tok.text == ">" || tok.text == ">="):
+		p.take()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ() != typeText || right.typ() != typeText {
+			return nil, p.errorAt(tok, "%s compares text or numbers, not conditions", tok.text)
+		}
+		return compareNode{op: tok.text, left: left, right: right}, nil
+
+	case isKeyword(tok, "", "in"), isKeyword(tok, "", "not") && isKeyword(p.tokens[p.next+1], "", "in"):
+		negate := isKeyword(p.take(), "", "not")
+		if negate {
+			p.take()
+		}
+		if left.typ() != typeText {
+			return nil, p.errorAt(tok, "in tests text or numbers, not conditions")
+		}
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return inNode{value: left, list: list, negate: negate}, nil
+	}
+	return left, nil
+}
+
+// parseList parses a bracketed, comma-separated list of text operands
+func (p *exprParser) parseList() ([]exprNode, error) {
+	if tok := p.take(); !isKeyword(tok, "[", "") {
+		return nil, p.errorAt(tok, "expected [ to start a list")
+	}
+	var list []exprNode
+	for {
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if item.typ() != typeText {
+			return nil, p.errorAt(p.peek(), "list items must be text or numbers")
+		}
+		list = append(list, item)
+
+		tok := p.take()
+		if isKeyword(tok, "]", "") {
+			return list, nil
+		}
+		if !isKeyword(tok, ",", "") {
+			return nil, p.errorAt(tok, "expected , or ] in a list")
+		}
+	}
+}
+
+// parseOperand parses text, a number, a field, a function call or a parenthesised expression
+func (p *exprParser) parseOperand() (exprNode, error) {
+	tok := p.take()
+	switch tok.kind {
+	case tokenText:
+		return literal{value: tok.text}, nil
+	case tokenNumber:
+		return literal{value: tok.text, number: true}, nil
+	case tokenName:
+		if isKeyword(p.peek(), "(", "") {
+			return p.parseCall(tok)
+		}
+		for _, keyword := range []string{"and", "or", "not", "in"} {
+			if strings.EqualFold(tok.text, keyword) {
+				return nil, p.errorAt(tok, "unexpected %q", tok.text)
+			}
+		}
+		ref, err := p.scope.resolve(tok.text)
+		if err != nil {
+			return nil, p.errorAt(tok, "%v", err)
+		}
+		return ref, nil
+	case tokenSymbol:
+		if tok.text == "(" {
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if closing := p.take(); !isKeyword(closing, ")", "") {
+				return nil, p.errorAt(closing, "expected )")
+			}
+			return node, nil
+		}
+	}
+	if tok.kind == tokenEnd {
+		return nil, p.errorAt(tok, "expression is incomplete")
+	}
+	return nil, p.errorAt(tok, "unexpected %q", tok.text)
+}
+
+// parseCall parses the arguments of a call to one of exprFunctions
+func (p *exprParser) parseCall(name token) (exprNode, error) {
+	function, ok := exprFunctions[strings.ToLower(name.text)]
+	if !ok {
+		return nil, p.errorAt(name, "unknown function %s", name.text)
+	}
+	p.take() // (
+
+	call := callNode{name: strings.ToLower(name.text)}
+	if !isKeyword(p.peek(), ")", "") {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if arg.typ() != typeText {
+				return nil, p.errorAt(name, "%s takes text or numbers, not conditions", call.name)
+			}
+			call.args = append(call.args, arg)
+			if !isKeyword(p.peek(), ",", "") {
+				break
+			}
+			p.take()
+		}
+	}
+	if closing := p.take(); !isKeyword(closing, ")", "") {
+		return nil, p.errorAt(closing, "expected ) after the arguments of %s", call.name)
+	}
+	if len(call.args) != function.args {
+		return nil, p.errorAt(name, "%s takes %d argument(s), not %d", call.name, function.args, len(call.args))
+	}
+	return call, nil
+}
@@ -0,0 +1,199 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldType describes the kind of value held by a field
+type FieldType int
+
+const (
+	FieldText    FieldType = iota // Free text (default)
+	FieldCode                     // Coded value made of letters and digits (e.g., GENDER, ATTEND)
+	FieldInteger                  // Whole number (e.g., CREDIT, FEE, years)
+	FieldDate                     // Date in DDMMYYYY format (e.g., DOB, CRS_SRT)
+	FieldDecimal                  // Decimal number; without a decimal point, Decimals places are implied (e.g., FACTOR)
+)
+
+// DateLayout is the SDR date format (DDMMYYYY) as a Go time layout
+const DateLayout = "02012006"
+
+// String returns the name of the field type
+func (t FieldType) String() string {
+	switch t {
+	case FieldText:
+		return "text"
+	case FieldCode:
+		return "code"
+	case FieldInteger:
+		return "integer"
+	case FieldDate:
+		return "date"
+	case FieldDecimal:
+		return "decimal"
+	default:
+		return fmt.Sprintf("FieldType(%d)", int(t))
+	}
+}
+
+// MarshalText writes the field type by name, as used in JSON spec files
+func (t FieldType) MarshalText() ([]byte, error) {
+	if t < FieldText || t > FieldDecimal {
+		return nil, fmt.Errorf("unknown field type %d", int(t))
+	}
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText reads a field type name, as used in JSON spec files
+func (t *FieldType) UnmarshalText(text []byte) error {
+	for ft := FieldText; ft <= FieldDecimal; ft++ {
+		if strings.EqualFold(string(text), ft.String()) {
+			*t = ft
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown field type %q", text)
+}
+
+// Check reports whether a trimmed, non-empty value is valid for the field's type
+func (f FieldSpec) Check(value string) error {
+	switch f.Type {
+	case FieldCode:
+		if !isAlphanumeric(value) {
+			return fmt.Errorf("invalid code %q (letters and digits only)", value)
+		}
+	case FieldInteger:
+		if _, err := f.Int(value); err != nil {
+			return err
+		}
+	case FieldDate:
+		if _, err := f.Date(value); err != nil {
+			return err
+		}
+	case FieldDecimal:
+		if _, err := f.Decimal(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Int converts a value of an integer field
+func (f FieldSpec) Int(value string) (int, error) {
+	if !isNumeric(value) || value == "" {
+		return 0, fmt.Errorf("invalid integer %q", value)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer %q", value)
+	}
+	return n, nil
+}
+
+// Date converts a value of a date field (DDMMYYYY)
+func (f FieldSpec) Date(value string) (time.Time, error) {
+	if len(value) != len(DateLayout) || !isNumeric(value) {
+		return time.Time{}, fmt.Errorf("invalid date %q (expected DDMMYYYY)", value)
+	}
+	date, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (not a calendar date)", value)
+	}
+	return date, nil
+}
+
+// Decimal converts a value of a decimal field.
+// Values without a decimal point have Decimals implied decimal places ("001167" is 0.1167 with 4 places).
+func (f FieldSpec) Decimal(value string) (float64, error) {
+	digits := strings.Replace(value, ".", "", 1)
+	if digits == "" || !isNumeric(digits) {
+		return 0, fmt.Errorf("invalid decimal %q", value)
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid decimal %q", value)
+	}
+	if !strings.Contains(value, ".") && f.Decimals > 0 {
+		n /= math.Pow10(f.Decimals)
+	}
+	return n, nil
+}
+
+// Field returns the spec of the named field
+func (s FileSpec) Field(name string) (FieldSpec, bool) {
+	for _, field := range s.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return FieldSpec{}, false
+}
+
+// KeyOf returns the record's natural key: the values of the spec's Key fields, joined.
+// It reports false if the spec has no key or every key field is blank.
+func (s FileSpec) KeyOf(record Record) (string, bool) {
+	values := make([]string, len(s.Key))
+	blank := true
+	for i, name := range s.Key {
+		values[i] = record.Get(name)
+		blank = blank && values[i] == ""
+	}
+	if blank {
+		return "", false
+	}
+	return joinKey(values...), true
+}
+
+// Int returns the named integer field of a record
+func (s FileSpec) Int(record Record, name string) (int, error) {
+	field, err := s.typedField(name, FieldInteger)
+	if err != nil {
+		return 0, err
+	}
+	return field.Int(record.Get(name))
+}
+
+// Date returns the named date field of a record
+func (s FileSpec) Date(record Record, name string) (time.Time, error) {
+	field, err := s.typedField(name, FieldDate)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return field.Date(record.Get(name))
+}
+
+// Decimal returns the named decimal field of a record
+func (s FileSpec) Decimal(record Record, name string) (float64, error) {
+	field, err := s.typedField(name, FieldDecimal)
+	if err != nil {
+		return 0, err
+	}
+	return field.Decimal(record.Get(name))
+}
+
+// typedField looks up a field and checks it has the expected type
+func (s FileSpec) typedField(name string, fieldType FieldType) (FieldSpec, error) {
+	field, ok := s.Field(name)
+	if !ok {
+		return FieldSpec{}, fmt.Errorf("%s has no field %s", s.FileType, name)
+	}
+	if field.Type != fieldType {
+		return FieldSpec{}, fmt.Errorf("field %s is %s, not %s", name, field.Type, fieldType)
+	}
+	return field, nil
+}
+
+// isAlphanumeric checks if a string contains only ASCII letters and digits
+func isAlphanumeric(s string) bool {
+	for _, char := range s {
+		if !(char >= '0' && char <= '9') && !(char >= 'A' && char <= 'Z') && !(char >= 'a' && char <= 'z') {
+			return false
+		}
+	}
+	return true
+}
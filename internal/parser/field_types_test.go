@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFieldSpec_Check(t *testing.T) {
+	tests := []struct {
+		field FieldSpec
+		value string
+		valid bool
+	}{
+		{FieldSpec{Name: "CTITLE", Type: FieldText}, "Te Reo, level 1", true},
+		{FieldSpec{Name: "GENDER", Type: FieldCode}, "F", true},
+		{FieldSpec{Name: "CATEGORY", Type: FieldCode}, "P1", true},
+		{FieldSpec{Name: "CATEGORY", Type: FieldCode}, "P-1", false},
+		{FieldSpec{Name: "CREDIT", Type: FieldInteger}, "14", true},
+		{FieldSpec{Name: "CREDIT", Type: FieldInteger}, "1A", false},
+		{FieldSpec{Name: "DOB", Type: FieldDate}, "29022024", true},
+		{FieldSpec{Name: "DOB", Type: FieldDate}, "29022023", false},
+		{FieldSpec{Name: "DOB", Type: FieldDate}, "2024-01-01", false},
+		{FieldSpec{Name: "FACTOR", Type: FieldDecimal, Decimals: 4}, "0.1167", true},
+		{FieldSpec{Name: "FACTOR", Type: FieldDecimal, Decimals: 4}, "001167", true},
+		{FieldSpec{Name: "FACTOR", Type: FieldDecimal, Decimals: 4}, "0.1.67", false},
+	}
+
+	for _, test := range tests {
+		err := test.field.Check(test.value)
+		if test.valid && err != nil {
+			t.Errorf("%s %q: expected valid, got: %v", test.field.Name, test.value, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s %q: expected error", test.field.Name, test.value)
+		}
+	}
+}
+
+func TestFieldSpec_ImpliedDecimal(t *testing.T) {
+	field := FieldSpec{Name: "FACTOR", Type: FieldDecimal, Decimals: 4}
+
+	for _, value := range []string{"0.1167", "001167"} {
+		n, err := field.Decimal(value)
+		if err != nil {
+			t.Fatalf("Decimal(%q) failed: %v", value, err)
+		}
+		if n != 0.1167 {
+			t.Errorf("Decimal(%q): expected 0.1167, got %v", value, n)
+		}
+	}
+}
+
+func TestFileSpec_TypedAccessors(t *testing.T) {
+	records, err := NewCREGParser().Parse(sampleCREGData)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	spec := GetCREGSpec()
+
+	credit, err := spec.Int(records[0], "CREDIT")
+	if err != nil || credit != 14 {
+		t.Errorf("Expected CREDIT 14, got %d (%v)", credit, err)
+	}
+
+	factor, err := spec.Decimal(records[0], "FACTOR")
+	if err != nil || factor != 0.1167 {
+		t.Errorf("Expected FACTOR 0.1167, got %v (%v)", factor, err)
+	}
+
+	// Asking for the wrong type is an error rather than a silent conversion
+	if _, err := spec.Date(records[0], "CREDIT"); err == nil {
+		t.Error("Expected error reading an integer field as a date")
+	}
+
+	record := NewRecord(NewRecordIndex([]string{"CRS_SRT"}))
+	record.Set("CRS_SRT", "28092023")
+	start, err := GetCOMPSpec().Date(record, "CRS_SRT")
+	if err != nil || !start.Equal(time.Date(2023, time.September, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected CRS_SRT 2023-09-28, got %v (%v)", start, err)
+	}
+}
+
+func TestFixedWidthParser_TypeErrors(t *testing.T) {
+	parser := NewCOMPParser()
+
+	// 30 February is not a calendar date. The value is kept, for the validator to report.
+	line := "9170917000047 2102-530            030022023 12033171106062024    "
+	validator := NewValidator(parser.spec)
+	if err := parser.ParseReader(strings.NewReader(line), validator.Check); err != nil {
+		t.Fatalf("ParseReader failed: %v", err)
+	}
+	findings := findingsByRule(validator.Findings(), "field-type")
+	if len(findings) != 1 || findings[0].Field != "CRS_SRT" || findings[0].Value != "30022023" {
+		t.Errorf("Expected one CRS_SRT finding, got %v", validator.Findings())
+	}
+
+	// In strict mode it stops the parse
+	parser.SetOptions(ParseOptions{Strict: true})
+	_, err := parser.Parse(line)
+	if err == nil {
+		t.Fatal("Expected error for invalid CRS_SRT")
+	}
+	if !strings.Contains(err.Error(), "field CRS_SRT") {
+		t.Errorf("Expected CRS_SRT error, got: %v", err)
+	}
+}
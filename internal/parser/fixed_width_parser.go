@@ -0,0 +1,413 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// FixedWidthParser parses any SDR file type described by a FileSpec.
+// The per-type parsers (STUDParser, CREGParser, ...) are thin wrappers around it
+// so every file type slices, pads and validates lines the same way.
+type FixedWidthParser struct {
+	spec         FileSpec
+	extraColumns []ExtraColumn       // Columns added by enrich, after the spec's columns
+	index        *RecordIndex        // Column positions shared by every record, matching GetColumnNames
+	enrich       func(record Record) // Optional hook adding data to each record
+	options      ParseOptions
+	lineErrors   []LineError // Lines skipped by the last parse in lenient mode
+	lineWarnings []LineError // Lines of the last parse that parsed but deserve a look
+	encoding     Encoding    // Encoding of the last parsed input, once detected
+}
+
+// NewFixedWidthParser creates a parser driven entirely by the given spec
+func NewFixedWidthParser(spec FileSpec) *FixedWidthParser {
+	p := &FixedWidthParser{
+		spec: spec,
+	}
+	p.index = NewRecordIndex(p.GetColumnNames())
+	return p
+}
+
+// maxLineBytes caps the length of a single line so a file without line breaks
+// cannot grow the scanner buffer without bound
+const maxLineBytes = 1024 * 1024
+
+// SetOptions sets the options used by subsequent parses
+func (p *FixedWidthParser) SetOptions(opts ParseOptions) {
+	p.options = opts
+}
+
+// GetLineErrors returns the lines skipped by the last parse in lenient mode
+func (p *FixedWidthParser) GetLineErrors() []LineError {
+	return p.lineErrors
+}
+
+// GetLineWarnings returns the lines of the last parse whose byte and character lengths differ
+func (p *FixedWidthParser) GetLineWarnings() []LineError {
+	return p.lineWarnings
+}
+
+// GetEncoding returns the encoding of the last parsed input
+func (p *FixedWidthParser) GetEncoding() Encoding {
+	return p.encoding
+}
+
+// Parse parses the content and returns its records.
+// In lenient mode the good records are returned and bad lines are available from GetLineErrors.
+func (p *FixedWidthParser) Parse(content string) ([]Record, error) {
+	var records []Record
+
+	err := p.ParseReader(strings.NewReader(content), func(record Record, src Source) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// ParseReader parses content line by line and calls fn for each record as soon as
+// it is parsed, so memory use does not grow with the size of the file.
+// Returning an error from fn stops parsing and the error is returned unchanged.
+// A bad line aborts parsing with a *LineError, unless the parser is lenient,
+// in which case the line is skipped and recorded for GetLineErrors. A value that does
+// not match its field's type makes a bad line only in strict mode; otherwise it is
+// kept, for a Validator to report.
+// The input is decoded to UTF-8 first, so field positions count characters as the SDR spec does.
+func (p *FixedWidthParser) ParseReader(r io.Reader, fn RecordFunc) error {
+	p.lineErrors = nil
+	p.lineWarnings = nil
+
+	decoded, encoding, bomLength, err := decodeInput(r, p.options.Encoding)
+	if err != nil {
+		return &LineError{Line: 1, Reason: err.Error()}
+	}
+	p.encoding = encoding
+
+	// Track the byte offset of each line in the original input as the scanner consumes it
+	offset, consumed := int64(bomLength), int64(bomLength)
+	scanner := bufio.NewScanner(decoded)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineBytes)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := scanLines(data, atEOF)
+		if token != nil {
+			offset = consumed
+		}
+		if encoding.singleByte() {
+			// Each decoded character was a single byte of the input
+			consumed += int64(utf8.RuneCount(data[:advance]))
+		} else {
+			consumed += int64(advance)
+		}
+		return advance, token, err
+	})
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+
+		// Skip blank lines. The line itself is never trimmed: leading blanks are
+		// part of the first field and trimming them would shift every field.
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		// Multi-byte characters make byte positions differ from the spec's character positions
+		if encoding == EncodingUTF8 && !isASCII(line) {
+			p.lineWarnings = append(p.lineWarnings, LineError{
+				Line:   lineNum,
+				Offset: offset,
+				Reason: fmt.Sprintf("line is %d bytes but %d characters; fields are positioned by character",
+					len(line), charCount(line)),
+				Raw: line,
+			})
+		}
+
+		var record Record
+		err := p.checkConformance(line)
+		if err == nil {
+			record, err = p.parseLine(line)
+		}
+		if err != nil {
+			var lineErr *LineError
+			if !errors.As(err, &lineErr) {
+				return err
+			}
+			lineErr.Line = lineNum
+			lineErr.Offset = offset
+			lineErr.Raw = line
+
+			if p.options.Lenient {
+				p.lineErrors = append(p.lineErrors, *lineErr)
+				continue
+			}
+			return lineErr
+		}
+
+		if p.enrich != nil {
+			p.enrich(record)
+		}
+
+		src := Source{File: p.options.SourceName, Line: lineNum, Offset: offset, Raw: line}
+		if err := fn(record, src); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return &LineError{Line: lineNum + 1, Offset: consumed, Reason: err.Error()}
+	}
+
+	return nil
+}
+
+// scanLines is a bufio.SplitFunc that accepts Windows (\r\n), Unix (\n) and
+// old Mac (\r) line endings
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		// A \r may be followed by a \n in the next read, so wait for more data
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		return 0, nil, nil
+	}
+
+	// Final line without a line ending
+	if atEOF {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
+
+// checkConformance reports, in strict mode, a line that would otherwise be quietly
+// padded, truncated or misread: a wrong length, data past the end of the layout,
+// tabs, or leading blanks that shift every field.
+// Errors are always a *LineError without the line number and raw text filled in.
+func (p *FixedWidthParser) checkConformance(line string) error {
+	if !p.options.Strict {
+		return nil
+	}
+
+	if i := strings.IndexRune(line, '\t'); i >= 0 {
+		position := charCount(line[:i]) + 1
+		return &LineError{
+			Field:  p.fieldAt(position),
+			Reason: fmt.Sprintf("tab character at position %d", position),
+		}
+	}
+
+	if len(p.spec.Fields) > 0 && p.spec.Fields[0].Required && strings.HasPrefix(line, " ") {
+		blanks := len(line) - len(strings.TrimLeft(line, " "))
+		return &LineError{
+			Field:  p.spec.Fields[0].Name,
+			Reason: fmt.Sprintf("line starts with %d blank(s), shifting every field", blanks),
+		}
+	}
+
+	length := charCount(line)
+	if length > p.spec.LineLength {
+		past := sliceChars(line, p.spec.LineLength, length)
+		if strings.TrimSpace(past) != "" {
+			return &LineError{
+				Reason: fmt.Sprintf("data past position %d: %q", p.spec.LineLength, strings.TrimSpace(past)),
+			}
+		}
+	}
+	if length != p.spec.LineLength {
+		return &LineError{
+			Reason: fmt.Sprintf("line is %d characters, expected %d", length, p.spec.LineLength),
+		}
+	}
+
+	return nil
+}
+
+// fieldAt returns the name of the field covering a 1-based position, or ""
+func (p *FixedWidthParser) fieldAt(position int) string {
+	for _, field := range p.spec.Fields {
+		if position >= field.Start && position < field.Start+field.Length {
+			return field.Name
+		}
+	}
+	return ""
+}
+
+// parseLine extracts fields from a single line into a record laid out by the parser's index.
+// Errors are always a *LineError without the line number and raw text filled in.
+func (p *FixedWidthParser) parseLine(line string) (Record, error) {
+	chars := newCharLine(p.normaliseLine(line))
+
+	// Columns follow spec.Columns(): each field and its label, then its sub-fields and total
+	record := NewRecord(p.index)
+	column := 0
+	for _, field := range p.spec.Fields {
+		value, err := fieldValue(chars, field, p.options.Strict)
+		if err != nil {
+			return Record{}, err
+		}
+		record.values[column] = value
+		column++
+		if field.LabelTitle != "" {
+			record.values[column] = field.Label(value)
+			column++
+		}
+
+		if len(field.SubFields) > 0 {
+			n, err := parseSubFields(record.values[column:], field, chars, p.options.Strict)
+			if err != nil {
+				return Record{}, err
+			}
+			column += n
+		}
+	}
+
+	return record, nil
+}
+
+// parseValues extracts the trimmed field values of a single line in spec order.
+// Errors are always a *LineError without the line number and raw text filled in.
+func (p *FixedWidthParser) parseValues(line string) ([]string, error) {
+	chars := newCharLine(p.normaliseLine(line))
+
+	values := make([]string, len(p.spec.Fields))
+	for i, field := range p.spec.Fields {
+		value, err := fieldValue(chars, field, p.options.Strict)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+// fieldValue extracts and checks the trimmed value of one field of a normalised line,
+// checking it against the field's type if checkType is set.
+// Errors are always a *LineError without the line number and raw text filled in.
+func fieldValue(line charLine, field FieldSpec, checkType bool) (string, error) {
+	// Convert 1-based position to 0-based for Go
+	start := field.Start - 1
+	end := start + field.Length
+
+	// Bounds checking
+	if start < 0 || end > line.length() {
+		return "", &LineError{
+			Field: field.Name,
+			Reason: fmt.Sprintf("field %s: position out of bounds (start: %d, end: %d, line length: %d)",
+				field.Name, start, end, line.length()),
+		}
+	}
+
+	// Trim both leading and trailing spaces
+	value := strings.TrimSpace(line.slice(start, end))
+
+	// Check required fields
+	if field.Required && value == "" {
+		return "", &LineError{
+			Field:  field.Name,
+			Reason: fmt.Sprintf("required field %s is empty", field.Name),
+		}
+	}
+
+	// Check the value against the field's type
+	if checkType && value != "" {
+		if err := field.Check(value); err != nil {
+			return "", &LineError{
+				Field:  field.Name,
+				Reason: fmt.Sprintf("field %s: %v", field.Name, err),
+			}
+		}
+	}
+
+	return value, nil
+}
+
+// normaliseLine pads or truncates a line to the spec's line length in characters
+func (p *FixedWidthParser) normaliseLine(line string) string {
+	length := charCount(line)
+	// Pad line to expected length if it's shorter (common with trailing spaces missing)
+	if length < p.spec.LineLength {
+		line = line + strings.Repeat(" ", p.spec.LineLength-length)
+	}
+	// Truncate line if it's longer (handle data quality issues)
+	if length > p.spec.LineLength {
+		line = sliceChars(line, 0, p.spec.LineLength)
+	}
+	return line
+}
+
+// setEnrichment appends columns to the output and sets the hook that fills them in
+func (p *FixedWidthParser) setEnrichment(columns []ExtraColumn, enrich func(record Record)) {
+	p.extraColumns = columns
+	p.index = NewRecordIndex(p.GetColumnNames())
+	p.enrich = enrich
+}
+
+// GetHeaders returns the column titles for CSV headers, followed by any enabled extra columns
+func (p *FixedWidthParser) GetHeaders() []string {
+	columns := p.spec.Columns()
+	headers := make([]string, 0, len(columns)+len(p.extraColumns))
+	for _, column := range columns {
+		headers = append(headers, column.Title)
+	}
+	for _, column := range p.extraColumns {
+		headers = append(headers, column.Title)
+	}
+	return headers
+}
+
+// GetColumnNames returns the record keys matching GetHeaders, in the same order.
+// Spacer columns have an empty name.
+func (p *FixedWidthParser) GetColumnNames() []string {
+	columns := p.spec.Columns()
+	names := make([]string, 0, len(columns)+len(p.extraColumns))
+	for _, column := range columns {
+		names = append(names, column.Name)
+	}
+	for _, column := range p.extraColumns {
+		names = append(names, column.Field)
+	}
+	return names
+}
+
+// GetFileType returns the file type
+func (p *FixedWidthParser) GetFileType() string {
+	return p.spec.FileType
+}
+
+// GetDescription returns the file description
+func (p *FixedWidthParser) GetDescription() string {
+	return p.spec.Description
+}
+
+// GetExpectedLineLength returns the expected line length
+func (p *FixedWidthParser) GetExpectedLineLength() int {
+	return p.spec.LineLength
+}
+
+// GetSpec returns the file specification
+func (p *FixedWidthParser) GetSpec() FileSpec {
+	return p.spec
+}
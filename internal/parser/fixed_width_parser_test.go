@@ -0,0 +1,320 @@
+package parser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// Minimal spec used to exercise the generic parser independently of the SDR layouts
+var testSpec = FileSpec{
+	FileType:    "TEST",
+	Description: "Test File",
+	LineLength:  10,
+	Fields: []FieldSpec{
+		{Name: "CODE", Title: "Code", Start: 1, Length: 4, Required: true},
+		{Name: "NAME", Title: "Name", Start: 5, Length: 5, Required: false},
+		{Name: "FLAG", Title: "Flag", Start: 10, Length: 1, Required: false},
+	},
+}
+
+func TestFixedWidthParser_Parse(t *testing.T) {
+	parser := NewFixedWidthParser(testSpec)
+
+	content := "1234ALPHAY\r\n5678BETA N\r\n"
+
+	records, err := parser.Parse(content)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+
+	if records[1].Get("CODE") != "5678" || records[1].Get("NAME") != "BETA" || records[1].Get("FLAG") != "N" {
+		t.Errorf("Unexpected second record: %v", records[1].Map())
+	}
+}
+
+func TestFixedWidthParser_LeadingBlanksAreNotTrimmed(t *testing.T) {
+	parser := NewFixedWidthParser(testSpec)
+
+	// A blank CODE must be reported, not silently filled by shifting the line left
+	_, err := parser.Parse("    ALPHAY")
+	if err == nil {
+		t.Fatal("Expected error for empty required field")
+	}
+
+	if !strings.Contains(err.Error(), "required field CODE") {
+		t.Errorf("Expected required field error for CODE, got: %v", err)
+	}
+}
+
+func TestFixedWidthParser_LineNumbers(t *testing.T) {
+	parser := NewFixedWidthParser(testSpec)
+
+	// Blank lines still count towards the reported line number
+	_, err := parser.Parse("1234ALPHAY\n\n    BETA N")
+	if err == nil {
+		t.Fatal("Expected error for empty required field")
+	}
+
+	if !strings.HasPrefix(err.Error(), "line 3:") {
+		t.Errorf("Expected error on line 3, got: %v", err)
+	}
+}
+
+func TestFixedWidthParser_WrappersShareBehaviour(t *testing.T) {
+	// Every SDR parser must treat a line with leading blanks the same way
+	parsers := []Parser{
+		NewSTUDParser(),
+		NewCourseEnrolmentParser(),
+		NewCREGParser(),
+		NewCOMPParser(),
+		NewQUALParser(),
+	}
+
+	for _, parser := range parsers {
+		_, err := parser.Parse("    " + strings.Repeat("X", 40))
+		if err == nil || !strings.Contains(err.Error(), "required field INSTIT") {
+			t.Errorf("%s: expected required field INSTIT error, got: %v", parser.GetFileType(), err)
+		}
+	}
+}
+
+func TestFixedWidthParser_ParseReader(t *testing.T) {
+	parser := NewFixedWidthParser(testSpec)
+
+	// One byte at a time so \r\n pairs are split across reads
+	input := iotest.OneByteReader(strings.NewReader("1234ALPHAY\r\n5678BETA N\r9012GAMMAY\n"))
+
+	var codes []string
+	var sources []Source
+	err := parser.ParseReader(input, func(record Record, src Source) error {
+		codes = append(codes, record.Get("CODE"))
+		sources = append(sources, src)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ParseReader failed: %v", err)
+	}
+
+	expected := []string{"1234", "5678", "9012"}
+	if strings.Join(codes, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected codes %v, got %v", expected, codes)
+	}
+
+	// Offsets count the line endings, whatever their style
+	expectedOffsets := []int64{0, 12, 23}
+	for i, src := range sources {
+		if src.Line != i+1 || src.Offset != expectedOffsets[i] {
+			t.Errorf("Record %d: expected line %d at offset %d, got line %d at offset %d",
+				i+1, i+1, expectedOffsets[i], src.Line, src.Offset)
+		}
+	}
+}
+
+func TestFixedWidthParser_ParseReaderStops(t *testing.T) {
+	parser := NewFixedWidthParser(testSpec)
+	stop := errors.New("stop")
+
+	calls := 0
+	err := parser.ParseReader(strings.NewReader("1234ALPHAY\n5678BETA N\n"), func(record Record, src Source) error {
+		calls++
+		return stop
+	})
+	if err != stop {
+		t.Errorf("Expected callback error to be returned unchanged, got: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected parsing to stop after 1 record, got %d calls", calls)
+	}
+}
+
+func TestCSVWriter_WriteCSVFromReader(t *testing.T) {
+	parser := NewCOMPParser()
+	outputPath := filepath.Join(t.TempDir(), "COMP9170_parsed.csv")
+
+	content := "9170917000047 2102-530            028092023 12033171106062024    \n" +
+		"9170917000440 2102-530            027022024 16264822205112024    \n"
+
+	count, err := NewCSVWriter().WriteCSVFromReader(strings.NewReader(content), outputPath, parser)
+	if err != nil {
+		t.Fatalf("WriteCSVFromReader failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 records, got %d", count)
+	}
+
+	rows := readCSV(t, outputPath)
+	if len(rows) != 3 || rows[2][1] != "917000440" {
+		t.Errorf("Unexpected CSV content: %v", rows)
+	}
+
+	// A parse error must not leave a partial CSV behind
+	_, err = NewCSVWriter().WriteCSVFromReader(strings.NewReader(content+"    bad line\n"), outputPath, parser)
+	if err == nil {
+		t.Fatal("Expected parse error")
+	}
+	if _, statErr := os.Stat(outputPath); !os.IsNotExist(statErr) {
+		t.Errorf("Expected partial output to be removed, stat returned: %v", statErr)
+	}
+}
+
+func TestFixedWidthParser_LenientMode(t *testing.T) {
+	parser := NewFixedWidthParser(testSpec)
+	parser.SetOptions(ParseOptions{Lenient: true})
+
+	content := "1234ALPHAY\n    BETA N\n5678GAMMAY\n     DELTA\n"
+
+	records, err := parser.Parse(content)
+	if err != nil {
+		t.Fatalf("Lenient parse should not fail: %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Errorf("Expected 2 good records, got %d", len(records))
+	}
+
+	lineErrors := parser.GetLineErrors()
+	if len(lineErrors) != 2 {
+		t.Fatalf("Expected 2 line errors, got %d", len(lineErrors))
+	}
+
+	first := lineErrors[0]
+	if first.Line != 2 || first.Field != "CODE" || first.Raw != "    BETA N" {
+		t.Errorf("Unexpected line error: %+v", first)
+	}
+	if first.Error() != "line 2: required field CODE is empty" {
+		t.Errorf("Unexpected error text: %s", first.Error())
+	}
+
+	// Errors are reset by the next parse
+	if _, err := parser.Parse("1234ALPHAY"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(parser.GetLineErrors()) != 0 {
+		t.Errorf("Expected line errors to be reset, got %d", len(parser.GetLineErrors()))
+	}
+}
+
+func TestFixedWidthParser_StrictMode(t *testing.T) {
+	content := "1234ALPHAY\n" + // Conforming
+		"5678BETA\n" + // Short: padded by default
+		"9012GAMMAY   \n" + // Trailing blanks past the end
+		"3456DELTAYX\n" + // Data past the end: truncated by default
+		"7890EP\tS Y\n" + // Tab inside NAME
+		" 1234ZETAY\n" // Leading blank shifts every field
+
+	// By default every line is quietly padded, truncated or read as it stands
+	parser := NewFixedWidthParser(testSpec)
+	parser.SetOptions(ParseOptions{Lenient: true})
+	if _, err := parser.Parse(content); err != nil {
+		t.Fatalf("Lenient parse should not fail: %v", err)
+	}
+	if len(parser.GetLineErrors()) != 0 {
+		t.Errorf("Expected no line errors without strict mode, got %+v", parser.GetLineErrors())
+	}
+
+	parser.SetOptions(ParseOptions{Lenient: true, Strict: true})
+	records, err := parser.Parse(content)
+	if err != nil {
+		t.Fatalf("Lenient parse should not fail: %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("Expected 1 conforming record, got %d", len(records))
+	}
+
+	expected := []struct {
+		line   int
+		field  string
+		reason string
+	}{
+		{2, "", "line is 8 characters, expected 10"},
+		{3, "", "line is 13 characters, expected 10"},
+		{4, "", "data past position 10: \"X\""},
+		{5, "NAME", "tab character at position 7"},
+		{6, "CODE", "line starts with 1 blank(s), shifting every field"},
+	}
+	lineErrors := parser.GetLineErrors()
+	if len(lineErrors) != len(expected) {
+		t.Fatalf("Expected %d line errors, got %+v", len(expected), lineErrors)
+	}
+	for i, want := range expected {
+		got := lineErrors[i]
+		if got.Line != want.line || got.Field != want.field || got.Reason != want.reason {
+			t.Errorf("Line error %d: expected line %d %s %q, got %+v", i, want.line, want.field, want.reason, got)
+		}
+	}
+
+	// Without Lenient the first mismatch stops the parse
+	parser.SetOptions(ParseOptions{Strict: true})
+	if _, err := parser.Parse(content); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected strict error for line 2, got: %v", err)
+	}
+}
+
+func TestProcessFileWithOptions_Lenient(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "QUAL9170.txt")
+	content := "9170917000478  140261767NZ2101            2024    \n" +
+		"9170917000441             NZ2101            2024    \n" +
+		"9170917000409  138474289NZ2101            2024    \n"
+	if err := os.WriteFile(inputPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	// Default mode aborts on the first bad line
+	result := ProcessFile(inputPath, dir)
+	if result.Success || result.Error == nil {
+		t.Fatal("Expected default mode to fail on missing NSN")
+	}
+
+	result = ProcessFileWithOptions(inputPath, dir, ProcessOptions{Lenient: true})
+	if !result.Success || !result.Partial {
+		t.Fatalf("Expected partial success, got %+v", result)
+	}
+	if result.RecordCount != 2 {
+		t.Errorf("Expected 2 records, got %d", result.RecordCount)
+	}
+	if len(result.LineErrors) != 1 || result.LineErrors[0].Line != 2 || result.LineErrors[0].Field != "NSN" {
+		t.Errorf("Unexpected line errors: %+v", result.LineErrors)
+	}
+}
+
+func TestProcessFileWithOptions_IncludeSource(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "QUAL9170.txt")
+	// Line 2 is blank, so the second record comes from line 3
+	content := "9170917000478  140261767NZ2101            2024    \n" +
+		"\n" +
+		"9170917000409  138474289NZ2101            2024    \n"
+	if err := os.WriteFile(inputPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	result := ProcessFileWithOptions(inputPath, dir, ProcessOptions{IncludeSource: true})
+	if !result.Success {
+		t.Fatalf("Expected success, got: %v", result.Error)
+	}
+
+	rows := readCSV(t, result.OutputFile)
+	last := len(rows[0]) - 1
+	if rows[0][last-3] != "Source File" || rows[0][last] != "Source Raw Line" {
+		t.Fatalf("Expected source columns at the end, got %v", rows[0])
+	}
+
+	expected := []string{"QUAL9170.txt", "3", "52", "9170917000409  138474289NZ2101            2024    "}
+	if got := rows[2][last-3:]; strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected source %v, got %v", expected, got)
+	}
+
+	// The source columns do not get in the way of rebuilding the file
+	if rebuilt := RebuildFile(result.OutputFile, dir, ProcessOptions{}); !rebuilt.Success {
+		t.Errorf("RebuildFile failed: %v", rebuilt.Error)
+	}
+}
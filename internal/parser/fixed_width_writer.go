@@ -0,0 +1,341 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FixedWidthWriter rebuilds fixed-width SDR lines from CSV, the reverse of FixedWidthParser
+type FixedWidthWriter struct {
+	spec       FileSpec
+	ignored    []string // Headers of derived columns (sub-field totals, joined columns) to skip
+	encoding   Encoding // Encoding of the lines written; UTF-8 when not set
+	lineErrors []LineError
+}
+
+// NewFixedWidthWriter creates a writer for the given spec
+func NewFixedWidthWriter(spec FileSpec) *FixedWidthWriter {
+	w := &FixedWidthWriter{spec: spec}
+
+	// Totals, labels, joined columns and source columns are added when parsing, so they have no place in the line
+	w.ignored = append(w.ignored, sourceHeaders...)
+	for _, field := range spec.Fields {
+		if field.TotalTitle != "" {
+			w.ignored = append(w.ignored, field.TotalTitle, field.TotalName())
+		}
+		for _, f := range append([]FieldSpec{field}, field.SubFields...) {
+			if f.LabelTitle != "" {
+				w.ignored = append(w.ignored, f.LabelTitle, f.LabelName())
+			}
+		}
+	}
+	for _, extra := range joinColumns(spec.FileType) {
+		w.ignored = append(w.ignored, extra.Title, extra.Field)
+	}
+	return w
+}
+
+// SetEncoding sets the character encoding of the lines written
+func (w *FixedWidthWriter) SetEncoding(enc Encoding) {
+	w.encoding = enc
+}
+
+// csvColumn says where the values of a CSV column go
+type csvColumn struct {
+	field  int // Index into spec.Fields, -1 if the column is ignored
+	sub    int // Index into the field's SubFields, -1 for the field itself
+	header string
+}
+
+// csvLayout maps the columns of a CSV file onto the spec
+type csvLayout struct {
+	columns      []csvColumn
+	fieldColumns []int   // CSV column of each field, -1 where there is none
+	subColumns   [][]int // CSV column of each sub-field, -1 where there is none
+}
+
+// WriteFromCSV reads CSV with a header row of field Titles (as written by CSVWriter) or
+// field Names and writes one padded fixed-width line per row to out.
+// Every row is checked; rows with errors are left out and reported by GetLineErrors,
+// and the returned error describes the first of them. It returns the number of lines written.
+func (w *FixedWidthWriter) WriteFromCSV(input io.Reader, out io.Writer) (int, error) {
+	w.lineErrors = nil
+
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1 // Column counts are checked per row so every row gets reported
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return 0, errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read CSV: %w", err)
+	}
+
+	layout, err := w.mapColumns(header)
+	if err != nil {
+		return 0, err
+	}
+
+	writer := bufio.NewWriter(out)
+	count := 0
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				w.lineErrors = append(w.lineErrors, LineError{Line: parseErr.Line, Reason: parseErr.Err.Error()})
+				continue
+			}
+			return 0, fmt.Errorf("failed to read CSV: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		if isBlankRow(row) {
+			continue
+		}
+		if len(row) != len(layout.columns) {
+			w.lineErrors = append(w.lineErrors, LineError{
+				Line:   line,
+				Reason: fmt.Sprintf("row has %d columns, header has %d", len(row), len(layout.columns)),
+			})
+			continue
+		}
+
+		text, lineErr := w.buildLine(layout, row)
+		if lineErr != nil {
+			lineErr.Line = line
+			lineErr.Raw = strings.Join(row, ",")
+			w.lineErrors = append(w.lineErrors, *lineErr)
+			continue
+		}
+
+		encoded, err := encodeString(text+"\n", w.encoding)
+		if err != nil {
+			// Cells were checked against the encoding, so this is a programming error
+			return 0, fmt.Errorf("failed to write SDR file: %w", err)
+		}
+		if _, err := writer.Write(encoded); err != nil {
+			return 0, fmt.Errorf("failed to write SDR file: %w", err)
+		}
+		count++
+	}
+
+	if err := writer.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to write SDR file: %w", err)
+	}
+
+	if len(w.lineErrors) > 0 {
+		first := w.lineErrors[0]
+		return count, fmt.Errorf("%d row(s) could not be written, first: %w", len(w.lineErrors), &first)
+	}
+	return count, nil
+}
+
+// GetLineErrors returns the rows rejected by the last WriteFromCSV
+func (w *FixedWidthWriter) GetLineErrors() []LineError {
+	return w.lineErrors
+}
+
+// mapColumns matches each CSV header to a field or sub-field by Title or Name
+func (w *FixedWidthWriter) mapColumns(header []string) (csvLayout, error) {
+	layout := csvLayout{
+		columns:      make([]csvColumn, len(header)),
+		fieldColumns: make([]int, len(w.spec.Fields)),
+		subColumns:   make([][]int, len(w.spec.Fields)),
+	}
+	for i, field := range w.spec.Fields {
+		layout.fieldColumns[i] = -1
+		for range field.SubFields {
+			layout.subColumns[i] = append(layout.subColumns[i], -1)
+		}
+	}
+
+	seen := make(map[[2]int]string)
+	covered := make(map[int]bool) // Fields with a column of their own or a sub-field column
+
+	for i, title := range header {
+		title = strings.TrimSpace(title)
+		column := csvColumn{field: -1, sub: -1, header: title}
+
+		if title != "" && !containsFold(w.ignored, title) {
+			field, sub, ok := w.findColumn(title)
+			if !ok {
+				return csvLayout{}, &LineError{
+					Line:   1,
+					Reason: fmt.Sprintf("column %d (%s) is not a field of the %s spec", i+1, title, w.spec.FileType),
+				}
+			}
+			key := [2]int{field, sub}
+			if other, exists := seen[key]; exists {
+				return csvLayout{}, &LineError{
+					Line:   1,
+					Reason: fmt.Sprintf("columns %s and %s (column %d) hold the same field", other, title, i+1),
+				}
+			}
+			seen[key] = title
+			covered[field] = true
+			column.field, column.sub = field, sub
+			if sub < 0 {
+				layout.fieldColumns[field] = i
+			} else {
+				layout.subColumns[field][sub] = i
+			}
+		}
+		layout.columns[i] = column
+	}
+
+	for i, field := range w.spec.Fields {
+		if field.Required && !covered[i] {
+			return csvLayout{}, &LineError{
+				Line:   1,
+				Field:  field.Name,
+				Reason: fmt.Sprintf("no column for required field %s (%s)", field.Name, field.Title),
+			}
+		}
+	}
+	return layout, nil
+}
+
+// findColumn returns the field, and sub-field if any, whose Title or Name matches a header
+func (w *FixedWidthWriter) findColumn(header string) (int, int, bool) {
+	for i, field := range w.spec.Fields {
+		if strings.EqualFold(header, field.Title) || strings.EqualFold(header, field.Name) {
+			return i, -1, true
+		}
+		for j, sub := range field.SubFields {
+			if strings.EqualFold(header, sub.Title) || strings.EqualFold(header, sub.Name) {
+				return i, j, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// buildLine lays out one CSV row as a fixed-width line
+func (w *FixedWidthWriter) buildLine(layout csvLayout, row []string) (string, *LineError) {
+	line := []rune(strings.Repeat(" ", w.spec.LineLength))
+	columns := layout.columns
+
+	for i, field := range w.spec.Fields {
+		col := layout.fieldColumns[i]
+		value := ""
+		if col >= 0 {
+			value = strings.TrimSpace(row[col])
+		}
+
+		// Sub-field columns are the readable form, so they are what gets edited
+		if hasAny(layout.subColumns[i]) {
+			packed, lineErr := w.packSubFields(field, layout.subColumns[i], columns, row)
+			if lineErr != nil {
+				return "", lineErr
+			}
+			if col >= 0 && value != strings.TrimSpace(packed) {
+				return "", &LineError{
+					Field:  field.Name,
+					Reason: fmt.Sprintf("column %d (%s) does not match its sub-field columns", col+1, columns[col].header),
+				}
+			}
+			copy(line[field.Start-1:], []rune(packed))
+			continue
+		}
+
+		if lineErr := w.checkCell(field, value, col, columns); lineErr != nil {
+			return "", lineErr
+		}
+		copy(line[field.Start-1:], []rune(alignValue(field, value)))
+	}
+
+	return string(line), nil
+}
+
+// packSubFields joins the sub-field values of a row into the packed value of their parent field
+func (w *FixedWidthWriter) packSubFields(field FieldSpec, subColumns []int, columns []csvColumn, row []string) (string, *LineError) {
+	packed := []rune(strings.Repeat(" ", field.Length))
+	for j, sub := range field.SubFields {
+		col := subColumns[j]
+		value := ""
+		if col >= 0 {
+			value = strings.TrimSpace(row[col])
+		}
+		if lineErr := w.checkCell(sub, value, col, columns); lineErr != nil {
+			return "", lineErr
+		}
+		copy(packed[sub.Start-1:], []rune(alignValue(sub, value)))
+	}
+	return string(packed), nil
+}
+
+// checkCell checks that a value fits its field: present if required, valid for
+// the field's type, no longer than the field and representable in the output encoding
+func (w *FixedWidthWriter) checkCell(field FieldSpec, value string, col int, columns []csvColumn) *LineError {
+	where := fmt.Sprintf("field %s", field.Name)
+	if col >= 0 {
+		where = fmt.Sprintf("column %d (%s)", col+1, columns[col].header)
+	}
+
+	if value == "" {
+		if field.Required {
+			return &LineError{Field: field.Name, Reason: fmt.Sprintf("%s: required field %s is empty", where, field.Name)}
+		}
+		return nil
+	}
+	if charCount(value) > field.Length {
+		return &LineError{
+			Field:  field.Name,
+			Reason: fmt.Sprintf("%s: value %q is %d characters, field %s allows %d", where, value, charCount(value), field.Name, field.Length),
+		}
+	}
+	if _, err := encodeString(value, w.encoding); err != nil {
+		return &LineError{Field: field.Name, Reason: fmt.Sprintf("%s: %v", where, err)}
+	}
+	if err := field.Check(value); err != nil {
+		return &LineError{Field: field.Name, Reason: fmt.Sprintf("%s: %v", where, err)}
+	}
+	return nil
+}
+
+// alignValue pads a value to its field length, on the left for right-aligned fields
+func alignValue(field FieldSpec, value string) string {
+	if field.RightAlign {
+		return fmt.Sprintf("%*s", field.Length, value)
+	}
+	return fmt.Sprintf("%-*s", field.Length, value)
+}
+
+// hasAny reports whether any column index is set
+func hasAny(columns []int) bool {
+	for _, col := range columns {
+		if col >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isBlankRow reports whether every cell of a CSV row is empty
+func isBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// containsFold reports whether list holds s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if item != "" && strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
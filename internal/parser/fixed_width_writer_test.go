@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFixedWidthWriter_FieldNames(t *testing.T) {
+	csvContent := "CODE,NAME,FLAG\n1234,ALPHA,Y\n5678,,N\n"
+
+	var out bytes.Buffer
+	count, err := NewFixedWidthWriter(testSpec).WriteFromCSV(strings.NewReader(csvContent), &out)
+	if err != nil {
+		t.Fatalf("WriteFromCSV failed: %v", err)
+	}
+
+	expected := "1234ALPHAY\n5678     N\n"
+	if count != 2 || out.String() != expected {
+		t.Errorf("Expected %q (2 lines), got %q (%d lines)", expected, out.String(), count)
+	}
+}
+
+func TestFixedWidthWriter_Overflow(t *testing.T) {
+	csvContent := "Code,Name,Flag\n1234,ALPHA,Y\n5678,TOOLONG,N\n"
+
+	var out bytes.Buffer
+	writer := NewFixedWidthWriter(testSpec)
+	count, err := writer.WriteFromCSV(strings.NewReader(csvContent), &out)
+	if err == nil {
+		t.Fatal("Expected error for overflowing value")
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 line written, got %d", count)
+	}
+
+	lineErrors := writer.GetLineErrors()
+	if len(lineErrors) != 1 {
+		t.Fatalf("Expected 1 line error, got %d", len(lineErrors))
+	}
+	lineErr := lineErrors[0]
+	if lineErr.Line != 3 || lineErr.Field != "NAME" || !strings.Contains(lineErr.Reason, "column 2 (Name)") {
+		t.Errorf("Unexpected line error: %+v", lineErr)
+	}
+}
+
+func TestFixedWidthWriter_UnknownColumn(t *testing.T) {
+	csvContent := "Code,Name,Colour\n1234,ALPHA,red\n"
+
+	_, err := NewFixedWidthWriter(testSpec).WriteFromCSV(strings.NewReader(csvContent), &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "column 3 (Colour)") {
+		t.Errorf("Expected unknown column error, got: %v", err)
+	}
+}
+
+func TestFixedWidthWriter_EditedMonthlyColumn(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "COUR9170.txt")
+	if err := os.WriteFile(inputPath, []byte(sniffSamples["COUR"]), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	parsed := ProcessFile(inputPath, dir)
+	if !parsed.Success {
+		t.Fatalf("ProcessFile failed: %v", parsed.Error)
+	}
+
+	// Edit "EFTS July" in the CSV and drop the packed column, as a user fixing the month would
+	rows := readCSV(t, parsed.OutputFile)
+	var csvContent strings.Builder
+	for _, row := range rows {
+		var cells []string
+		for i, cell := range row {
+			switch rows[0][i] {
+			case "EFTS by Month":
+				continue
+			case "EFTS July":
+				if cell != "EFTS July" {
+					cell = "0.0050"
+				}
+			}
+			cells = append(cells, cell)
+		}
+		csvContent.WriteString(strings.Join(cells, ",") + "\n")
+	}
+
+	var out bytes.Buffer
+	if _, err := NewFixedWidthWriter(CourseEnrolmentSpec).WriteFromCSV(strings.NewReader(csvContent.String()), &out); err != nil {
+		t.Fatalf("WriteFromCSV failed: %v", err)
+	}
+
+	records, err := NewCourseEnrolmentParser().Parse(out.String())
+	if err != nil {
+		t.Fatalf("Rebuilt line does not parse: %v", err)
+	}
+	if records[0].Get("EFTS_MTH_07") != "0.0050" || records[0].Get("EFTS_MTH_06") != "0.0114" {
+		t.Errorf("Expected edited July EFTS 0.0050 and June 0.0114, got %s and %s",
+			records[0].Get("EFTS_MTH_07"), records[0].Get("EFTS_MTH_06"))
+	}
+}
+
+func TestRebuildFile_RoundTrip(t *testing.T) {
+	// Every sample fixture, so the alignment of each field is checked against sample data
+	fixtures := []struct{ fileType, content string }{{"STUD", sampleSTUDCodedData}}
+	for fileType, content := range sniffSamples {
+		fixtures = append(fixtures, struct{ fileType, content string }{fileType, content})
+	}
+
+	for _, fixture := range fixtures {
+		fileType, content := fixture.fileType, fixture.content
+		dir := t.TempDir()
+		inputPath := filepath.Join(dir, fileType+"9170.txt")
+		if err := os.WriteFile(inputPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+
+		parsed := ProcessFile(inputPath, dir)
+		if !parsed.Success {
+			t.Fatalf("%s: ProcessFile failed: %v", fileType, parsed.Error)
+		}
+
+		rebuilt := RebuildFile(parsed.OutputFile, dir, ProcessOptions{})
+		if !rebuilt.Success {
+			t.Fatalf("%s: RebuildFile failed: %v", fileType, rebuilt.Error)
+		}
+		if rebuilt.FileType != fileType || filepath.Base(rebuilt.OutputFile) != fileType+"9170_rebuilt.txt" {
+			t.Errorf("%s: unexpected result %s, %s", fileType, rebuilt.FileType, rebuilt.OutputFile)
+		}
+
+		data, err := os.ReadFile(rebuilt.OutputFile)
+		if err != nil {
+			t.Fatalf("%s: failed to read rebuilt file: %v", fileType, err)
+		}
+
+		// The rebuilt lines match the originals padded to the line length
+		reg, _ := LookupFileType(fileType)
+		parser := NewFixedWidthParser(reg.Spec)
+		original := strings.Split(strings.TrimSpace(content), "\n")
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if len(lines) != len(original) {
+			t.Fatalf("%s: expected %d lines, got %d", fileType, len(original), len(lines))
+		}
+		for i := range original {
+			if expected := parser.normaliseLine(original[i]); lines[i] != expected {
+				t.Errorf("%s line %d:\nexpected %q\ngot      %q", fileType, i+1, expected, lines[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,435 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// joinFileExt is the extension of join files in a join directory
+const joinFileExt = ".json"
+
+// Values of joined columns that have no single value to show
+const (
+	joinMissing  = "N/A"      // Default when no source record matches, or the source file is missing
+	joinConflict = "CONFLICT" // Source records that share the join key disagree on the value
+)
+
+// ExtraColumn is an additional CSV column appended after a file type's spec fields
+type ExtraColumn struct {
+	Title string // CSV header (empty for a spacer column)
+	Field string // Record key holding the value (empty for a spacer column)
+}
+
+// joinSpacers separate the joined columns from the file's own fields
+var joinSpacers = []ExtraColumn{{}, {}}
+
+// Join brings values from the records of one file type onto the records of another,
+// matched on key fields, when comparison data is generated. The source file is found
+// next to the target file (COMP9170.txt for COUR9170.txt).
+type Join struct {
+	Name    string       `json:"name"`             // Join name in warnings, e.g. "cour-completion"
+	Target  string       `json:"target"`           // File type whose CSV gets the columns
+	Source  string       `json:"source"`           // File type the values come from
+	On      []string     `json:"on,omitempty"`     // Fields present in both; defaults to the source's Key
+	Columns []JoinColumn `json:"columns"`          // Source fields to bring in
+	Prefix  string       `json:"prefix,omitempty"` // Starts the default column names and titles; defaults to Source
+	Missing string       `json:"missing,omitempty"`
+}
+
+// JoinColumn is one source field brought onto the target records
+type JoinColumn struct {
+	Field string `json:"field"`           // Source column (a field, sub-field or label)
+	Name  string `json:"name,omitempty"`  // Record key; defaults to PREFIX_FIELD
+	Title string `json:"title,omitempty"` // CSV header; defaults to "PREFIX <source title>"
+}
+
+// builtInJoins are the joins in use unless a join directory replaces them.
+// Other lookups, such as CREG or STUD values onto COUR, are added in a join file.
+var builtInJoins = []Join{
+	{
+		Name:   "cour-completion",
+		Target: "COUR",
+		Source: "COMP",
+		On:     []string{"ID", "COURSE", "CRS_SRT"},
+		Columns: []JoinColumn{
+			{Field: "COMPLETE", Name: "COMPLETE", Title: "Student Course Completion indicator"},
+		},
+	},
+}
+
+// joins holds the joins in use
+var joins = builtInJoins
+
+// Joins returns the joins in use
+func Joins() []Join {
+	return joins
+}
+
+// UseJoins checks joins and, if they are all valid, replaces the joins in use
+func UseJoins(list []Join) error {
+	names := make(map[string]bool)
+	for _, join := range list {
+		if names[join.Name] {
+			return fmt.Errorf("join %s is defined more than once", join.Name)
+		}
+		names[join.Name] = true
+	}
+	if _, err := resolveJoins(list, ""); err != nil {
+		return err
+	}
+	joins = list
+	return nil
+}
+
+// resolveJoins returns the joins onto a file type (every join, if fileType is empty) with
+// their defaults filled in, checking them against the registered specs
+func resolveJoins(list []Join, fileType string) ([]Join, error) {
+	var resolved []Join
+	columns := make(map[string]map[string]string) // Target -> column name or title -> join
+	for _, join := range list {
+		if fileType != "" && !strings.EqualFold(join.Target, fileType) {
+			continue
+		}
+		if err := join.resolve(); err != nil {
+			return nil, err
+		}
+
+		if columns[join.Target] == nil {
+			columns[join.Target] = make(map[string]string)
+		}
+		for _, column := range join.Columns {
+			for _, key := range []string{strings.ToUpper(column.Name), strings.ToUpper(column.Title)} {
+				if other, exists := columns[join.Target][key]; exists {
+					return nil, fmt.Errorf("join %s: column %s is also added by join %s", join.Name, column.Name, other)
+				}
+				columns[join.Target][key] = join.Name
+			}
+		}
+		resolved = append(resolved, join)
+	}
+	return resolved, nil
+}
+
+// resolve checks the join and fills in its defaults
+func (j *Join) resolve() error {
+	if j.Name == "" {
+		return errors.New("join has no name")
+	}
+	j.Target, j.Source = strings.ToUpper(j.Target), strings.ToUpper(j.Source)
+	target, ok := LookupFileType(j.Target)
+	if !ok {
+		return fmt.Errorf("join %s: unknown target file type %q", j.Name, j.Target)
+	}
+	source, ok := LookupFileType(j.Source)
+	if !ok {
+		return fmt.Errorf("join %s: unknown source file type %q", j.Name, j.Source)
+	}
+	if j.Target == j.Source {
+		return fmt.Errorf("join %s: source and target are both %s", j.Name, j.Target)
+	}
+
+	j.On = append([]string{}, j.On...)
+	if len(j.On) == 0 {
+		j.On = append([]string{}, source.Spec.Key...) // Not shared: the names are upper-cased in place
+	}
+	if len(j.On) == 0 {
+		return fmt.Errorf("join %s: %s has no key, so the join needs \"on\" fields", j.Name, j.Source)
+	}
+	for i, name := range j.On {
+		j.On[i] = strings.ToUpper(name)
+		if _, ok := target.Spec.Field(j.On[i]); !ok {
+			return fmt.Errorf("join %s: %s has no field %s to join on", j.Name, j.Target, j.On[i])
+		}
+		if _, ok := source.Spec.Field(j.On[i]); !ok {
+			return fmt.Errorf("join %s: %s has no field %s to join on", j.Name, j.Source, j.On[i])
+		}
+	}
+
+	if j.Prefix == "" {
+		j.Prefix = j.Source
+	}
+	if j.Missing == "" {
+		j.Missing = joinMissing
+	}
+	if len(j.Columns) == 0 {
+		return fmt.Errorf("join %s: no columns", j.Name)
+	}
+
+	titles := make(map[string]string)
+	for _, column := range source.Spec.Columns() {
+		titles[column.Name] = column.Title
+	}
+	j.Columns = append([]JoinColumn{}, j.Columns...)
+	for i := range j.Columns {
+		column := &j.Columns[i]
+		column.Field = strings.ToUpper(column.Field)
+		title, ok := titles[column.Field]
+		if !ok {
+			return fmt.Errorf("join %s: %s has no column %s", j.Name, j.Source, column.Field)
+		}
+		if column.Name == "" {
+			column.Name = j.Prefix + "_" + column.Field
+		}
+		if column.Title == "" {
+			column.Title = j.Prefix + " " + title
+		}
+		for _, own := range target.Spec.Columns() {
+			if strings.EqualFold(column.Name, own.Name) || strings.EqualFold(column.Title, own.Title) ||
+				strings.EqualFold(column.Name, own.Title) || strings.EqualFold(column.Title, own.Name) {
+				return fmt.Errorf("join %s: column %s (%s) clashes with %s column %s", j.Name, column.Name, column.Title, j.Target, own.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// joinColumns returns the columns the joins in use add to a file type
+func joinColumns(fileType string) []ExtraColumn {
+	resolved, err := resolveJoins(joins, fileType)
+	if err != nil {
+		return nil
+	}
+	var columns []ExtraColumn
+	for _, join := range resolved {
+		for _, column := range join.Columns {
+			columns = append(columns, ExtraColumn{Title: column.Title, Field: column.Name})
+		}
+	}
+	return columns
+}
+
+// enrichment adds the values of every join onto the records of one file
+type enrichment struct {
+	columns  []ExtraColumn // Spacers, then the joined columns
+	lookups  []joinLookup
+	warnings []string // Problems loading the source files, for ProcessorResult.ComparisonWarnings
+}
+
+// joinLookup is the column values of a join, by join key
+type joinLookup struct {
+	join   Join
+	values map[string][]string
+}
+
+// enricher is implemented by parsers that can add columns to the records they parse
+type enricher interface {
+	setEnrichment(columns []ExtraColumn, enrich func(record Record))
+}
+
+// loadEnrichment loads the source file of each join onto the file at targetPath.
+// Problems are warnings: the joined columns then show the join's Missing value.
+func loadEnrichment(targetPath, fileType string, opts ProcessOptions) (*enrichment, error) {
+	resolved, err := resolveJoins(joins, fileType)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &enrichment{}
+	if len(resolved) > 0 {
+		e.columns = append(e.columns, joinSpacers...)
+	}
+	for _, join := range resolved {
+		for _, column := range join.Columns {
+			e.columns = append(e.columns, ExtraColumn{Title: column.Title, Field: column.Name})
+		}
+
+		lookup := joinLookup{join: join}
+		sourcePath := findJoinSource(targetPath, fileType, join.Source)
+		if sourcePath == "" {
+			e.warnings = append(e.warnings, fmt.Sprintf("No %s file found in the same directory - %s data will show as %s",
+				join.Source, join.Source, join.Missing))
+		} else if err := lookup.load(sourcePath, opts, &e.warnings); err != nil {
+			e.warnings = append(e.warnings, fmt.Sprintf("Failed to read %s file (%s): %v - %s data will show as %s",
+				join.Source, filepath.Base(sourcePath), err, join.Source, join.Missing))
+			lookup.values = nil
+		}
+		e.lookups = append(e.lookups, lookup)
+	}
+	return e, nil
+}
+
+// load indexes the source file's column values by join key. Records that share
+// a key are listed in warnings, and a value they disagree on becomes joinConflict.
+func (l *joinLookup) load(sourcePath string, opts ProcessOptions, warnings *[]string) error {
+	layout, err := detectLayout(sourcePath, opts.Year)
+	if err != nil {
+		return err
+	}
+	if layout.detection.FileType != l.join.Source {
+		return fmt.Errorf("it holds %s records, not %s", layout.detection.FileType, l.join.Source)
+	}
+	parser, err := GetParserForYear(l.join.Source, layout.year)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(sourcePath)
+	parser.SetOptions(ParseOptions{Lenient: true, SourceName: name, Encoding: opts.Encoding})
+
+	file, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	l.values = make(map[string][]string)
+	keys := newKeyIndex()
+	err = parser.ParseReader(bufio.NewReader(file), func(record Record, src Source) error {
+		key := recordKey(record, l.join.On)
+		values := make([]string, len(l.join.Columns))
+		for i, column := range l.join.Columns {
+			values[i] = record.Get(column.Field)
+		}
+		if keys.add(key, src) {
+			for i, previous := range l.values[key] {
+				if previous != values[i] {
+					values[i] = joinConflict
+				}
+			}
+		}
+		l.values[key] = values
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if skipped := len(parser.GetLineErrors()); skipped > 0 {
+		*warnings = append(*warnings, fmt.Sprintf("%s: %d line(s) could not be parsed and were left out of join %s", name, skipped, l.join.Name))
+	}
+	// Records that share a key cannot both be looked up; report them rather than pick one
+	for _, group := range keys.duplicates() {
+		var conflicts []string
+		for i, value := range l.values[group.key] {
+			if value == joinConflict {
+				conflicts = append(conflicts, l.join.Columns[i].Field)
+			}
+		}
+		outcome := "they agree on the joined values"
+		if len(conflicts) > 0 {
+			outcome = fmt.Sprintf("they disagree on %s, which will show as %s", strings.Join(conflicts, ", "), joinConflict)
+		}
+		*warnings = append(*warnings, fmt.Sprintf("%s lines %s share %s %s; %s",
+			name, listLines(group.sources), strings.Join(l.join.On, "+"), strings.ReplaceAll(group.key, "||", ", "), outcome))
+	}
+	return nil
+}
+
+// enrich sets the joined columns of a target record
+func (e *enrichment) enrich(record Record) {
+	for _, lookup := range e.lookups {
+		values, found := lookup.values[recordKey(record, lookup.join.On)]
+		for i, column := range lookup.join.Columns {
+			value := lookup.join.Missing
+			if found {
+				value = values[i]
+			}
+			record.Set(column.Name, value)
+		}
+	}
+}
+
+// findJoinSource looks for the source file type's file next to the target file, named like it
+// (COMP9170.txt for COUR9170.txt) or just by type (COMP.txt), in any letter case
+func findJoinSource(targetPath, targetType, sourceType string) string {
+	dir := filepath.Dir(targetPath)
+	name := strings.ToUpper(filepath.Base(targetPath))
+	ext := filepath.Ext(name)
+
+	candidates := []string{sourceType + ext}
+	if strings.HasPrefix(name, targetType) {
+		candidates = append([]string{sourceType + strings.TrimPrefix(name, targetType)}, candidates...)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, candidate := range candidates {
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.ToUpper(entry.Name()) == candidate {
+				return filepath.Join(dir, entry.Name())
+			}
+		}
+	}
+	return ""
+}
+
+// DefaultJoinDir returns the per-user directory searched for join files
+func DefaultJoinDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config directory: %w", err)
+	}
+	return filepath.Join(configDir, "oh-no-sdr", "joins"), nil
+}
+
+// ReadJoinFile reads a JSON array of joins
+func ReadJoinFile(path string) ([]Join, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read join file: %w", err)
+	}
+	defer file.Close()
+
+	var list []Join
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields() // A misspelt key would otherwise be silently ignored
+	if err := decoder.Decode(&list); err != nil {
+		return nil, fmt.Errorf("invalid join file %s: %w", filepath.Base(path), err)
+	}
+	if _, err := resolveJoins(list, ""); err != nil {
+		return nil, fmt.Errorf("invalid join file %s: %w", filepath.Base(path), err)
+	}
+	return list, nil
+}
+
+// LoadJoinDir reads every JSON join file in a directory and puts their joins in use
+// instead of the built-in ones, so a join can be changed or dropped without code changes.
+// Nothing is installed unless every join file is valid.
+func LoadJoinDir(dir string) ([]Join, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read join directory: %w", err)
+	}
+
+	var list []Join
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), joinFileExt) {
+			continue
+		}
+
+		fileJoins, err := ReadJoinFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, fileJoins...)
+	}
+
+	if err := UseJoins(list); err != nil {
+		return nil, fmt.Errorf("invalid joins in %s: %w", dir, err)
+	}
+	return list, nil
+}
+
+// ExportJoins writes the joins in use to joins.json in dir, creating dir if needed, as a
+// starting point for a join directory. It returns the path written.
+func ExportJoins(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create join directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(joins, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to write joins: %w", err)
+	}
+	path := filepath.Join(dir, "joins"+joinFileExt)
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("failed to write joins: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,207 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTestJoins puts joins in use for the rest of a test
+func useTestJoins(t *testing.T, list ...Join) {
+	t.Helper()
+	saved := joins
+	t.Cleanup(func() { joins = saved })
+	if err := UseJoins(list); err != nil {
+		t.Fatalf("UseJoins failed: %v", err)
+	}
+}
+
+func TestBuiltInJoins(t *testing.T) {
+	resolved, err := resolveJoins(builtInJoins, "")
+	if err != nil {
+		t.Fatalf("Built-in joins are invalid: %v", err)
+	}
+	for _, join := range resolved {
+		if source, _ := LookupFileType(join.Source); &join.On[0] == &source.Spec.Key[0] {
+			t.Errorf("%s: expected the join to copy the %s key rather than share it", join.Name, join.Source)
+		}
+	}
+}
+
+func TestProcessFileWithOptions_Joins(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"COUR9170.txt": sniffSamples["COUR"] + "\n" + withField(CourseEnrolmentSpec, sniffSamples["COUR"], "COURSE", "2102-999"),
+		"comp9170.txt": strings.Split(sniffSamples["COMP"], "\n")[0], // Found in any letter case
+		"CREG.txt":     sampleCREGData,                               // Found by type alone
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	// By default only the COMP completion indicator is added
+	result := ProcessFileWithOptions(filepath.Join(dir, "COUR9170.txt"), dir, ProcessOptions{EnableComparison: true})
+	if !result.Success {
+		t.Fatalf("Expected success, got %v", result.Error)
+	}
+	if len(result.ComparisonWarnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", result.ComparisonWarnings)
+	}
+	rows := readCSV(t, result.OutputFile)
+	if headers := rows[0]; headers[len(headers)-1] != "Student Course Completion indicator" ||
+		headers[len(headers)-2] != "" || len(headers) != len(NewCourseEnrolmentParser().GetHeaders())+3 {
+		t.Errorf("Expected two spacers and the completion indicator after the COUR fields, got %v", headers)
+	}
+
+	// Course and student values are joined on when a join file asks for them
+	useTestJoins(t, builtInJoins[0],
+		Join{Name: "cour-course", Target: "COUR", Source: "CREG", Columns: []JoinColumn{{Field: "CTITLE"}, {Field: "NZQCFLEVEL"}, {Field: "CREDIT"}}},
+		Join{Name: "cour-student", Target: "COUR", Source: "STUD", Columns: []JoinColumn{{Field: "DOB"}, {Field: "GENDER"}}},
+	)
+	result = ProcessFileWithOptions(filepath.Join(dir, "COUR9170.txt"), dir, ProcessOptions{EnableComparison: true})
+	if !result.Success {
+		t.Fatalf("Expected success, got %v", result.Error)
+	}
+	if len(result.ComparisonWarnings) != 1 || !strings.Contains(result.ComparisonWarnings[0], "No STUD file") {
+		t.Errorf("Expected a warning about the missing STUD file, got %v", result.ComparisonWarnings)
+	}
+
+	rows = readCSV(t, result.OutputFile)
+	column := make(map[string]int)
+	for i, title := range rows[0] {
+		column[title] = i
+	}
+	for _, expected := range []struct {
+		title       string
+		first, last string
+	}{
+		{"Student Course Completion indicator", "0", "N/A"},
+		{"CREG Course Title", "Food Product Development", "N/A"},
+		{"CREG Credit", "14", "N/A"},
+		{"STUD Date of Birth", "N/A", "N/A"},
+	} {
+		i, ok := column[expected.title]
+		if !ok {
+			t.Errorf("Expected a %q column in %v", expected.title, rows[0])
+			continue
+		}
+		if rows[1][i] != expected.first || rows[2][i] != expected.last {
+			t.Errorf("%s: expected %q and %q, got %q and %q", expected.title, expected.first, expected.last, rows[1][i], rows[2][i])
+		}
+	}
+
+	// The joined columns are left out when the CSV is turned back into an SDR file
+	rebuilt := RebuildFile(result.OutputFile, dir, ProcessOptions{})
+	if !rebuilt.Success {
+		t.Fatalf("Rebuild failed: %v", rebuilt.Error)
+	}
+	content, err := os.ReadFile(rebuilt.OutputFile)
+	if err != nil {
+		t.Fatalf("Failed to read rebuilt file: %v", err)
+	}
+	if lines := strings.Split(strings.TrimRight(string(content), "\r\n"), "\n"); strings.TrimRight(lines[0], "\r") != sniffSamples["COUR"] {
+		t.Errorf("Expected the original line back, got %q", lines[0])
+	}
+}
+
+func TestJoin_DuplicateSourceRecords(t *testing.T) {
+	dir := t.TempDir()
+	comp := strings.Split(sniffSamples["COMP"], "\n")
+	compSpec := GetCOMPSpec()
+	content := strings.Join([]string{
+		comp[0],
+		comp[1],
+		withField(compSpec, comp[0], "COMPLETE", "2"), // Same enrolment, another outcome
+		comp[1], // Same enrolment and outcome
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(dir, "COMP9170.txt"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write COMP file: %v", err)
+	}
+	useTestJoins(t, builtInJoins[0])
+
+	e, err := loadEnrichment(filepath.Join(dir, "COUR9170.txt"), "COUR", ProcessOptions{})
+	if err != nil {
+		t.Fatalf("loadEnrichment failed: %v", err)
+	}
+	if len(e.columns) != 3 || e.columns[2].Field != "COMPLETE" {
+		t.Fatalf("Expected two spacers and COMPLETE, got %v", e.columns)
+	}
+
+	values := e.lookups[0].values
+	if completion := values[joinKey("917000047", "2102-530", "28092023")]; completion[0] != joinConflict {
+		t.Errorf("Expected %s for disagreeing rows, got %s", joinConflict, completion)
+	}
+	if completion := values[joinKey("917000440", "2102-530", "27022024")]; completion[0] != "0" {
+		t.Errorf("Expected 0 for agreeing rows, got %s", completion)
+	}
+
+	if len(e.warnings) != 2 ||
+		e.warnings[0] != "COMP9170.txt lines 1, 3 share ID+COURSE+CRS_SRT 917000047, 2102-530, 28092023; they disagree on COMPLETE, which will show as CONFLICT" ||
+		!strings.Contains(e.warnings[1], "lines 2, 4") || !strings.HasSuffix(e.warnings[1], "they agree on the joined values") {
+		t.Errorf("Unexpected warnings: %v", e.warnings)
+	}
+}
+
+func TestLoadJoinDir(t *testing.T) {
+	saved := joins
+	defer func() { joins = saved }()
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	write("course.json", `[{"name": "comp-factor", "target": "comp", "source": "creg", "prefix": "CRS",
+		"columns": [{"field": "factor"}, {"field": "CATEGORY", "name": "FUND_CAT", "title": "Funding category of the course"}]}]`)
+	write("notes.txt", "not a join file")
+
+	loaded, err := LoadJoinDir(dir)
+	if err != nil {
+		t.Fatalf("LoadJoinDir failed: %v", err)
+	}
+	if len(loaded) != 1 || len(Joins()) != 1 {
+		t.Fatalf("Expected the join file to replace the built-in joins, got %v", Joins())
+	}
+	if columns := joinColumns("COUR"); len(columns) != 0 {
+		t.Errorf("Expected no joins onto COUR, got %v", columns)
+	}
+	columns := joinColumns("COMP")
+	if len(columns) != 2 || columns[0] != (ExtraColumn{Title: "CRS Course EFTS Factor", Field: "CRS_FACTOR"}) ||
+		columns[1] != (ExtraColumn{Title: "Funding category of the course", Field: "FUND_CAT"}) {
+		t.Errorf("Unexpected COMP columns: %v", columns)
+	}
+
+	for name, content := range map[string]string{
+		"field":   `[{"name": "j", "target": "COUR", "source": "CREG", "columns": [{"field": "NOPE"}]}]`,
+		"on":      `[{"name": "j", "target": "CREG", "source": "STUD", "columns": [{"field": "DOB"}]}]`,
+		"clash":   `[{"name": "j", "target": "COUR", "source": "CREG", "columns": [{"field": "CTITLE", "name": "COURSE"}]}]`,
+		"twice":   `[{"name": "j", "target": "COUR", "source": "CREG", "columns": [{"field": "CTITLE"}]}, {"name": "k", "target": "COUR", "source": "CREG", "columns": [{"field": "CTITLE"}]}]`,
+		"unknown": `[{"name": "j", "target": "COUR", "source": "CREG", "colums": []}]`,
+	} {
+		bad := t.TempDir()
+		if err := os.WriteFile(filepath.Join(bad, "joins.json"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write join file: %v", err)
+		}
+		if _, err := LoadJoinDir(bad); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if len(Joins()) != 1 || Joins()[0].Name != "comp-factor" {
+		t.Errorf("Invalid join files changed the joins in use: %v", Joins())
+	}
+
+	// The built-in joins can be exported and loaded back
+	joins = builtInJoins
+	path, err := ExportJoins(t.TempDir())
+	if err != nil {
+		t.Fatalf("ExportJoins failed: %v", err)
+	}
+	exported, err := ReadJoinFile(path)
+	if err != nil || len(exported) != len(builtInJoins) {
+		t.Errorf("Expected %d exported joins, got %d (%v)", len(builtInJoins), len(exported), err)
+	}
+}
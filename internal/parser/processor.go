@@ -1,10 +1,13 @@
 package parser
 
 import (
+	"bufio"
 	"encoding/csv"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -14,12 +17,38 @@ type ProcessorResult struct {
 	OutputFile  string
 	RecordCount int
 	FileType    string
+	Confidence  float64 // How well the content matched FileType's layout, 0 to 1
+	Year        int     // Collection year whose layout was used, 0 for the current layout
 	Success     bool
-	Error       error
+	Partial     bool        // CSV was written but some lines were skipped (lenient mode)
+	LineErrors  []LineError // Lines skipped in lenient mode
+	Warnings    []LineError // Lines that parsed but deserve a look (e.g., multi-byte characters)
+	Encoding    Encoding    // Character encoding of the input, once detected
+	Findings    []Finding   // Validation findings, when validation was requested
+	// Problems loading related files for comparison (e.g., no COMP file for a COUR file)
+	ComparisonWarnings []string
+	Error              error
 }
 
+// ProcessOptions controls how ProcessFileWithOptions converts a file
+type ProcessOptions struct {
+	EnableComparison bool     // Add columns from related files through the joins in use (e.g., COMP completion onto COUR)
+	Lenient          bool     // Skip bad lines and report them instead of aborting
+	Strict           bool     // Treat lines that do not match the layout exactly as bad lines
+	Year             int      // Collection year of the file, which picks its layout; 0 uses the current layout
+	IncludeSource    bool     // Add the source file, line, byte offset and raw line to each CSV row
+	Encoding         Encoding // Character encoding of SDR files read and written; detected when not set
+	Validate         bool     // Check each record (e.g., coded values against their code sets) while converting
+}
+
+// sourceHeaders are the CSV headers of the optional source columns
+var sourceHeaders = []string{"Source File", "Source Line", "Source Byte Offset", "Source Raw Line"}
+
 // CSVWriter handles writing parsed data to CSV files
-type CSVWriter struct{}
+type CSVWriter struct {
+	IncludeSource bool       // Append the source columns to each row written from a reader
+	OnRecord      RecordFunc // Optional hook called with each record written from a reader
+}
 
 // NewCSVWriter creates a new CSV writer
 func NewCSVWriter() *CSVWriter {
@@ -27,7 +56,7 @@ func NewCSVWriter() *CSVWriter {
 }
 
 // WriteCSV writes parsed records to a CSV file
-func (w *CSVWriter) WriteCSV(records []map[string]string, headers []string, outputPath string, parser Parser) error {
+func (w *CSVWriter) WriteCSV(records []Record, headers []string, outputPath string, parser Parser) error {
 	// Create output file
 	file, err := os.Create(outputPath)
 	if err != nil {
@@ -44,104 +73,83 @@ func (w *CSVWriter) WriteCSV(records []map[string]string, headers []string, outp
 		return fmt.Errorf("failed to write headers: %w", err)
 	}
 
-	// Handle different parser types
-	if studParser, ok := parser.(*STUDParser); ok {
-		return w.writeSTUDRecords(writer, records, studParser)
-	} else if courParser, ok := parser.(*CourseEnrolmentParser); ok {
-		return w.writeCourseEnrolmentRecords(writer, records, courParser)
-	} else if cregParser, ok := parser.(*CREGParser); ok {
-		return w.writeCREGRecords(writer, records, cregParser)
-	} else if compParser, ok := parser.(*COMPParser); ok {
-		return w.writeCOMPRecords(writer, records, compParser)
-	} else if qualParser, ok := parser.(*QUALParser); ok {
-		return w.writeQUALRecords(writer, records, qualParser)
-	}
-
-	return fmt.Errorf("unsupported parser type")
-}
-
-// writeSTUDRecords writes STUD records using the proper field mapping
-func (w *CSVWriter) writeSTUDRecords(writer *csv.Writer, records []map[string]string, parser *STUDParser) error {
+	// Records are laid out as parser.GetColumnNames, so their values are the row
 	for i, record := range records {
-		row := make([]string, len(parser.spec.Fields))
-		for j, field := range parser.spec.Fields {
-			row[j] = record[field.Name]
-		}
-
-		if err := writer.Write(row); err != nil {
+		if err := writer.Write(record.Values()); err != nil {
 			return fmt.Errorf("failed to write record %d: %w", i+1, err)
 		}
 	}
+
 	return nil
 }
 
-// writeCourseEnrolmentRecords writes COUR records using the proper field mapping
-func (w *CSVWriter) writeCourseEnrolmentRecords(writer *csv.Writer, records []map[string]string, parser *CourseEnrolmentParser) error {
-	for i, record := range records {
-		// Create row based on original fields
-		row := make([]string, len(parser.spec.Fields))
-		for j, field := range parser.spec.Fields {
-			row[j] = record[field.Name]
-		}
-
-		// Add comparison data if enabled
-		if parser.comparisonEnabled {
-			// Add empty columns for spacing
-			row = append(row, "", "")
-			// Add completion status
-			row = append(row, record["COMPLETE"])
-		}
+// WriteCSVFromReader parses input with the parser and writes each record to a CSV file
+// as soon as it is parsed, so memory use stays flat regardless of file size.
+// It returns the number of records written. On failure the partial output file is removed.
+func (w *CSVWriter) WriteCSVFromReader(input io.Reader, outputPath string, parser Parser) (int, error) {
+	// Create output file
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create output file: %w", err)
+	}
 
-		if err := writer.Write(row); err != nil {
-			return fmt.Errorf("failed to write record %d: %w", i+1, err)
-		}
+	count, err := w.writeStream(file, input, parser)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write CSV: %w", closeErr)
 	}
-	return nil
+	if err != nil {
+		os.Remove(outputPath)
+		return 0, err
+	}
+
+	return count, nil
 }
 
-// writeCREGRecords writes CREG records using the proper field mapping
-func (w *CSVWriter) writeCREGRecords(writer *csv.Writer, records []map[string]string, parser *CREGParser) error {
-	for i, record := range records {
-		row := make([]string, len(parser.spec.Fields))
-		for j, field := range parser.spec.Fields {
-			row[j] = record[field.Name]
-		}
+// writeStream writes the headers and every parsed record to out
+func (w *CSVWriter) writeStream(out io.Writer, input io.Reader, parser Parser) (int, error) {
+	writer := csv.NewWriter(out)
 
-		if err := writer.Write(row); err != nil {
-			return fmt.Errorf("failed to write record %d: %w", i+1, err)
-		}
+	// Write headers
+	headers := parser.GetHeaders()
+	if w.IncludeSource {
+		headers = append(headers[:len(headers):len(headers)], sourceHeaders...)
+	}
+	if err := writer.Write(headers); err != nil {
+		return 0, fmt.Errorf("failed to write CSV: failed to write headers: %w", err)
 	}
-	return nil
-}
 
-// writeCOMPRecords writes COMP records using the proper field mapping
-func (w *CSVWriter) writeCOMPRecords(writer *csv.Writer, records []map[string]string, parser *COMPParser) error {
-	for i, record := range records {
-		row := make([]string, len(parser.spec.Fields))
-		for j, field := range parser.spec.Fields {
-			row[j] = record[field.Name]
-		}
+	count := 0
+	var writeErr error
 
+	// Records are laid out as parser.GetColumnNames, so their values are the row
+	err := parser.ParseReader(input, func(record Record, src Source) error {
+		row := record.Values()
+		if w.IncludeSource {
+			row = append(row[:len(row):len(row)], src.File, strconv.Itoa(src.Line), strconv.FormatInt(src.Offset, 10), src.Raw)
+		}
 		if err := writer.Write(row); err != nil {
-			return fmt.Errorf("failed to write record %d: %w", i+1, err)
+			writeErr = fmt.Errorf("failed to write CSV: failed to write record %d: %w", count+1, err)
+			return writeErr
 		}
-	}
-	return nil
-}
-
-// writeQUALRecords writes QUAL records using the proper field mapping
-func (w *CSVWriter) writeQUALRecords(writer *csv.Writer, records []map[string]string, parser *QUALParser) error {
-	for i, record := range records {
-		row := make([]string, len(parser.spec.Fields))
-		for j, field := range parser.spec.Fields {
-			row[j] = record[field.Name]
+		count++
+		if w.OnRecord != nil {
+			return w.OnRecord(record, src)
 		}
-
-		if err := writer.Write(row); err != nil {
-			return fmt.Errorf("failed to write record %d: %w", i+1, err)
+		return nil
+	})
+	if err != nil {
+		if err == writeErr {
+			return 0, err
 		}
+		return 0, fmt.Errorf("failed to parse file: %w", err)
 	}
-	return nil
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return 0, fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	return count, nil
 }
 
 // ProcessFile processes a single SDR file
@@ -151,118 +159,316 @@ func ProcessFile(inputPath string, outputDir string) ProcessorResult {
 
 // ProcessFileWithComparison processes a single SDR file with optional comparison mode
 func ProcessFileWithComparison(inputPath string, outputDir string, enableComparison bool) ProcessorResult {
+	return ProcessFileWithOptions(inputPath, outputDir, ProcessOptions{EnableComparison: enableComparison})
+}
+
+// ProcessFileWithOptions processes a single SDR file
+func ProcessFileWithOptions(inputPath string, outputDir string, opts ProcessOptions) ProcessorResult {
 	result := ProcessorResult{
 		InputFile: inputPath,
 		Success:   false,
 	}
 
-	// Read input file
-	content, err := os.ReadFile(inputPath)
-	if err != nil {
-		result.Error = fmt.Errorf("failed to read input file: %w", err)
-		return result
-	}
-
-	// Normalize line endings before processing
-	// First replace all \r\n with \n
-	contentStr := strings.ReplaceAll(string(content), "\r\n", "\n")
-	// Then replace any remaining \r with \n
-	contentStr = strings.ReplaceAll(contentStr, "\r", "\n")
-
-	// Determine file type from filename
 	filename := filepath.Base(inputPath)
-	fileType := DetectFileType(filename)
-	if fileType == "" {
-		result.Error = fmt.Errorf("unable to determine file type from filename: %s", filename)
+	layout, err := detectLayout(inputPath, opts.Year)
+	result.FileType = layout.detection.FileType
+	result.Confidence = layout.detection.Confidence
+	if err != nil {
+		result.Error = err
 		return result
 	}
-
-	result.FileType = fileType
+	fileType, year := layout.detection.FileType, layout.year
+	result.Year = year
 
 	// Get appropriate parser
-	parser, err := GetParser(fileType)
+	parser, err := GetParserForYear(fileType, year)
 	if err != nil {
 		result.Error = fmt.Errorf("failed to get parser for file type %s: %w", fileType, err)
 		return result
 	}
 
-	// Enable comparison mode for COUR files if requested
-	if enableComparison && fileType == "COUR" {
-		if courParser, ok := parser.(*CourseEnrolmentParser); ok {
-			if err := courParser.EnableComparison(inputPath); err != nil {
+	parser.SetOptions(ParseOptions{
+		Lenient:    opts.Lenient,
+		Strict:     opts.Strict,
+		SourceName: filename,
+		Encoding:   opts.Encoding,
+	})
+
+	// Add columns from related files through the joins onto this file type
+	if opts.EnableComparison {
+		if enr, ok := parser.(enricher); ok {
+			enrichment, err := loadEnrichment(inputPath, fileType, opts)
+			if err != nil {
 				result.Error = fmt.Errorf("failed to enable comparison mode: %w", err)
 				return result
 			}
-
-			// Log any warnings about comparison data loading
-			warnings := courParser.GetComparisonWarnings()
-			for _, warning := range warnings {
-				fmt.Printf("Warning: %s\n", warning)
+			if len(enrichment.columns) > 0 {
+				enr.setEnrichment(enrichment.columns, enrichment.enrich)
 			}
+			result.ComparisonWarnings = enrichment.warnings
 		}
 	}
 
-	// Parse content
-	records, err := parser.Parse(contentStr)
+	// Open input file; it is streamed rather than read into memory
+	input, err := os.Open(inputPath)
 	if err != nil {
-		result.Error = fmt.Errorf("failed to parse file: %w", err)
+		result.Error = fmt.Errorf("failed to read input file: %w", err)
 		return result
 	}
-
-	result.RecordCount = len(records)
+	defer input.Close()
 
 	// Generate output filename
 	baseFilename := strings.TrimSuffix(filename, ".txt")
 	outputFilename := fmt.Sprintf("%s_parsed.csv", baseFilename)
 	outputPath := filepath.Join(outputDir, outputFilename)
-	result.OutputFile = outputPath
 
-	// Write CSV
+	// Parse and write CSV row by row
 	csvWriter := NewCSVWriter()
-	headers := parser.GetHeaders()
+	csvWriter.IncludeSource = opts.IncludeSource
+	var validator *Validator
+	if opts.Validate {
+		validator = NewValidator(layout.spec)
+		validator.SetYear(layout.year)
+		csvWriter.OnRecord = validator.Check
+	}
+	count, err := csvWriter.WriteCSVFromReader(bufio.NewReader(input), outputPath, parser)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	if validator != nil {
+		result.Findings = validator.Findings()
+	}
+
+	result.RecordCount = count
+	result.OutputFile = outputPath
+
+	result.Encoding = parser.GetEncoding()
+	result.Warnings = parser.GetLineWarnings()
+
+	// In lenient mode, skipped lines make the result a partial success
+	result.LineErrors = parser.GetLineErrors()
+	result.Partial = len(result.LineErrors) > 0
+	result.Success = true
+	return result
+}
+
+// fileLayout is the detected type of an SDR file and the layout that reads it
+type fileLayout struct {
+	detection Detection
+	year      int      // Collection year, 0 if unknown
+	spec      FileSpec // Layout for the collection year
+}
+
+// detectLayout determines the file type from the content, falling back to the filename,
+// and picks the layout for the collection year (the current layout if year is 0).
+// The detection is returned even when picking the layout fails.
+func detectLayout(inputPath string, year int) (fileLayout, error) {
+	detection, err := DetectFile(inputPath)
+	if err != nil {
+		return fileLayout{}, err
+	}
+	layout := fileLayout{detection: detection}
+
+	reg, ok := LookupFileType(detection.FileType)
+	if !ok {
+		return layout, fmt.Errorf("unable to determine file type from content or filename: %s", filepath.Base(inputPath))
+	}
+
+	layout.year = year
+	layout.spec = reg.SpecForYear(year)
+	return layout, nil
+}
+
+// rebuiltSuffix ends the name of the SDR files written by RebuildFile
+const rebuiltSuffix = "_rebuilt.txt"
 
-	if err := csvWriter.WriteCSV(records, headers, outputPath, parser); err != nil {
-		result.Error = fmt.Errorf("failed to write CSV: %w", err)
+// IsRebuiltFile reports whether a file name is that of a file written by RebuildFile
+func IsRebuiltFile(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), rebuiltSuffix)
+}
+
+// RebuildFile converts a CSV file, typically a corrected _parsed.csv, back into a
+// fixed-width SDR file named <name>_rebuilt.txt. The layout is chosen from the CSV
+// headers, falling back to the filename; opts.Year picks the collection year's layout.
+// If any row cannot be written, no output file is left behind and LineErrors lists every bad row.
+func RebuildFile(inputPath string, outputDir string, opts ProcessOptions) ProcessorResult {
+	result := ProcessorResult{
+		InputFile: inputPath,
+		Success:   false,
+	}
+
+	input, err := os.Open(inputPath)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to read input file: %w", err)
 		return result
 	}
+	defer input.Close()
 
+	// Spreadsheet programs may add a byte order mark or save in Windows-1252
+	decoded, _, _, err := decodeInput(input, EncodingAuto)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to read input file: %w", err)
+		return result
+	}
+	header, err := csv.NewReader(decoded).Read()
+	if err != nil {
+		result.Error = fmt.Errorf("failed to read CSV header: %w", err)
+		return result
+	}
+
+	filename := filepath.Base(inputPath)
+	spec, confidence, ok := DetectCSVSpec(header, filename, opts.Year)
+	if !ok {
+		result.Error = fmt.Errorf("unable to determine file type from CSV headers or filename: %s", filename)
+		return result
+	}
+	result.FileType = spec.FileType
+	result.Confidence = confidence
+	result.Year = opts.Year
+
+	if _, err := input.Seek(0, io.SeekStart); err != nil {
+		result.Error = fmt.Errorf("failed to read input file: %w", err)
+		return result
+	}
+	decoded, _, _, err = decodeInput(input, EncodingAuto)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to read input file: %w", err)
+		return result
+	}
+
+	// Generate output filename
+	baseFilename := strings.TrimSuffix(strings.TrimSuffix(filename, ".csv"), "_parsed")
+	outputPath := filepath.Join(outputDir, baseFilename+rebuiltSuffix)
+
+	output, err := os.Create(outputPath)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to create output file: %w", err)
+		return result
+	}
+
+	// Rebuilt files are written as UTF-8 unless another encoding is asked for
+	result.Encoding = opts.Encoding
+	if result.Encoding == EncodingAuto {
+		result.Encoding = EncodingUTF8
+	}
+	writer := NewFixedWidthWriter(spec)
+	writer.SetEncoding(result.Encoding)
+	count, err := writer.WriteFromCSV(decoded, output)
+	if closeErr := output.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write SDR file: %w", closeErr)
+	}
+	result.LineErrors = writer.GetLineErrors()
+	if err != nil {
+		// A file missing rows must not be mistaken for one ready to submit
+		os.Remove(outputPath)
+		result.Error = err
+		return result
+	}
+
+	result.RecordCount = count
+	result.OutputFile = outputPath
 	result.Success = true
 	return result
 }
 
-// DetectFileType attempts to determine file type from filename
-func DetectFileType(filename string) string {
-	upper := strings.ToUpper(filename)
+// DetectCSVSpec picks the layout whose field Titles or Names best match CSV headers.
+// When the headers are inconclusive, the file type comes from the filename hints.
+// A non-zero year selects that year's layout of the detected file type.
+func DetectCSVSpec(header []string, filename string, year int) (FileSpec, float64, bool) {
+	var best FileSpec
+	bestScore := 0.0
+	for _, reg := range registry {
+		for _, spec := range reg.Specs() {
+			// Strictly greater keeps the current layout when an earlier one matches as well
+			if score := headerScore(spec, joinColumns(reg.Spec.FileType), header); score > bestScore {
+				best, bestScore = spec, score
+			}
+		}
+	}
+
+	if bestScore < minConfidence {
+		fileType := DetectFileType(filename)
+		if fileType == "" {
+			return FileSpec{}, 0, false
+		}
+		reg, _ := LookupFileType(fileType)
+		best, bestScore = reg.Spec, 0
+	}
+
+	if year != 0 {
+		reg, _ := LookupFileType(best.FileType)
+		best = reg.SpecForYear(year)
+	}
+	return best, bestScore, true
+}
+
+// headerScore scores how well CSV headers fit a spec, from 0 to 1: half for the
+// fraction of headers the spec knows and half for the fraction of fields with a column
+func headerScore(spec FileSpec, extra []ExtraColumn, header []string) float64 {
+	known := make(map[string]bool)
+	for _, column := range spec.Columns() {
+		known[strings.ToUpper(column.Name)] = true
+		known[strings.ToUpper(column.Title)] = true
+	}
+	for _, column := range extra {
+		known[strings.ToUpper(column.Title)] = true
+	}
+	for _, title := range sourceHeaders {
+		known[strings.ToUpper(title)] = true
+	}
+
+	present := make(map[string]bool)
+	total, matched := 0, 0
+	for _, title := range header {
+		title = strings.ToUpper(strings.TrimSpace(title))
+		if title == "" {
+			continue
+		}
+		present[title] = true
+		total++
+		if known[title] {
+			matched++
+		}
+	}
+	if total == 0 || len(spec.Fields) == 0 {
+		return 0
+	}
+
+	covered := 0
+	for _, field := range spec.Fields {
+		if present[strings.ToUpper(field.Title)] || present[strings.ToUpper(field.Name)] {
+			covered++
+		}
+	}
+
+	return 0.5*float64(matched)/float64(total) + 0.5*float64(covered)/float64(len(spec.Fields))
+}
 
-	if strings.Contains(upper, "STUD") {
-		return "STUD"
-	} else if strings.Contains(upper, "COUR") {
-		return "COUR"
-	} else if strings.Contains(upper, "CREG") {
-		return "CREG"
-	} else if strings.Contains(upper, "COMP") {
-		return "COMP"
-	} else if strings.Contains(upper, "QUAL") {
-		return "QUAL"
+// DetectFileType attempts to determine file type from filename.
+// Registered file types are tried in registration order.
+// Prefer DetectFile, which looks at the content and only falls back to the filename.
+func DetectFileType(filename string) string {
+	for _, reg := range registry {
+		if reg.MatchesFilename(filename) {
+			return reg.Spec.FileType
+		}
 	}
 
 	return ""
 }
 
-// GetParser returns the appropriate parser for a given file type
+// GetParser returns the appropriate parser for a given file type, using its current layout
 func GetParser(fileType string) (Parser, error) {
-	switch strings.ToUpper(fileType) {
-	case "STUD":
-		return NewSTUDParser(), nil
-	case "COUR":
-		return NewCourseEnrolmentParser(), nil
-	case "CREG":
-		return NewCREGParser(), nil
-	case "COMP":
-		return NewCOMPParser(), nil
-	case "QUAL":
-		return NewQUALParser(), nil
-	default:
+	return GetParserForYear(fileType, 0)
+}
+
+// GetParserForYear returns a parser for the file type's layout in a collection year.
+// A year of 0 means the current layout.
+func GetParserForYear(fileType string, year int) (Parser, error) {
+	reg, ok := LookupFileType(fileType)
+	if !ok {
 		return nil, fmt.Errorf("unsupported file type: %s", fileType)
 	}
+	return reg.newParser(reg.SpecForYear(year)), nil
 }
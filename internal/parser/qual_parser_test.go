@@ -23,24 +23,24 @@ func TestQUALParser_Parse(t *testing.T) {
 	}
 
 	// Test first record
-	if records[0]["INSTIT"] != "9170" {
-		t.Errorf("Expected INSTIT '9170', got '%s'", records[0]["INSTIT"])
+	if records[0].Get("INSTIT") != "9170" {
+		t.Errorf("Expected INSTIT '9170', got '%s'", records[0].Get("INSTIT"))
 	}
 
-	if records[0]["ID"] != "917000478" {
-		t.Errorf("Expected ID '917000478', got '%s'", records[0]["ID"])
+	if records[0].Get("ID") != "917000478" {
+		t.Errorf("Expected ID '917000478', got '%s'", records[0].Get("ID"))
 	}
 
-	if records[0]["NSN"] != "140261767" {
-		t.Errorf("Expected NSN '140261767', got '%s'", records[0]["NSN"])
+	if records[0].Get("NSN") != "140261767" {
+		t.Errorf("Expected NSN '140261767', got '%s'", records[0].Get("NSN"))
 	}
 
-	if records[0]["QUAL"] != "NZ2101" {
-		t.Errorf("Expected QUAL 'NZ2101', got '%s'", records[0]["QUAL"])
+	if records[0].Get("QUAL") != "NZ2101" {
+		t.Errorf("Expected QUAL 'NZ2101', got '%s'", records[0].Get("QUAL"))
 	}
 
-	if records[0]["YR_REQ_MET"] != "2024" {
-		t.Errorf("Expected YR_REQ_MET '2024', got '%s'", records[0]["YR_REQ_MET"])
+	if records[0].Get("YR_REQ_MET") != "2024" {
+		t.Errorf("Expected YR_REQ_MET '2024', got '%s'", records[0].Get("YR_REQ_MET"))
 	}
 }
 
@@ -95,24 +95,24 @@ func TestQUALParser_ParseLine(t *testing.T) {
 	}
 
 	// Verify all fields are extracted correctly
-	if record["INSTIT"] != "9170" {
-		t.Errorf("INSTIT: expected '9170', got '%s'", record["INSTIT"])
+	if record.Get("INSTIT") != "9170" {
+		t.Errorf("INSTIT: expected '9170', got '%s'", record.Get("INSTIT"))
 	}
 
-	if record["ID"] != "917000478" {
-		t.Errorf("ID: expected '917000478', got '%s'", record["ID"])
+	if record.Get("ID") != "917000478" {
+		t.Errorf("ID: expected '917000478', got '%s'", record.Get("ID"))
 	}
 
-	if record["NSN"] != "140261767" {
-		t.Errorf("NSN: expected '140261767', got '%s'", record["NSN"])
+	if record.Get("NSN") != "140261767" {
+		t.Errorf("NSN: expected '140261767', got '%s'", record.Get("NSN"))
 	}
 
-	if record["QUAL"] != "NZ2101" {
-		t.Errorf("QUAL: expected 'NZ2101', got '%s'", record["QUAL"])
+	if record.Get("QUAL") != "NZ2101" {
+		t.Errorf("QUAL: expected 'NZ2101', got '%s'", record.Get("QUAL"))
 	}
 
-	if record["YR_REQ_MET"] != "2024" {
-		t.Errorf("YR_REQ_MET: expected '2024', got '%s'", record["YR_REQ_MET"])
+	if record.Get("YR_REQ_MET") != "2024" {
+		t.Errorf("YR_REQ_MET: expected '2024', got '%s'", record.Get("YR_REQ_MET"))
 	}
 }
 
@@ -199,8 +199,8 @@ func TestQUALParser_MultipleRecords(t *testing.T) {
 	// Verify different NSNs
 	expectedNSNs := []string{"140261767", "171046090", "138474289"}
 	for i, expectedNSN := range expectedNSNs {
-		if records[i]["NSN"] != expectedNSN {
-			t.Errorf("Record %d: expected NSN '%s', got '%s'", i, expectedNSN, records[i]["NSN"])
+		if records[i].Get("NSN") != expectedNSN {
+			t.Errorf("Record %d: expected NSN '%s', got '%s'", i, expectedNSN, records[i].Get("NSN"))
 		}
 	}
 }
@@ -226,11 +226,11 @@ func TestQUALParser_RealWorldData(t *testing.T) {
 
 	// Verify all records have the same qualification
 	for i, record := range records {
-		if record["QUAL"] != "NZ2101" {
-			t.Errorf("Record %d: expected QUAL 'NZ2101', got '%s'", i, record["QUAL"])
+		if record.Get("QUAL") != "NZ2101" {
+			t.Errorf("Record %d: expected QUAL 'NZ2101', got '%s'", i, record.Get("QUAL"))
 		}
-		if record["YR_REQ_MET"] != "2024" {
-			t.Errorf("Record %d: expected YR_REQ_MET '2024', got '%s'", i, record["YR_REQ_MET"])
+		if record.Get("YR_REQ_MET") != "2024" {
+			t.Errorf("Record %d: expected YR_REQ_MET '2024', got '%s'", i, record.Get("YR_REQ_MET"))
 		}
 	}
 }
@@ -250,24 +250,24 @@ func TestQUALParser_FieldExtraction(t *testing.T) {
 	}
 
 	// Verify exact field extraction
-	if record["INSTIT"] != "9170" {
-		t.Errorf("INSTIT extraction failed: got '%s'", record["INSTIT"])
+	if record.Get("INSTIT") != "9170" {
+		t.Errorf("INSTIT extraction failed: got '%s'", record.Get("INSTIT"))
 	}
 
-	if record["ID"] != "917000478" {
-		t.Errorf("ID extraction failed: got '%s'", record["ID"])
+	if record.Get("ID") != "917000478" {
+		t.Errorf("ID extraction failed: got '%s'", record.Get("ID"))
 	}
 
-	if record["NSN"] != "140261767" {
-		t.Errorf("NSN extraction failed: got '%s'", record["NSN"])
+	if record.Get("NSN") != "140261767" {
+		t.Errorf("NSN extraction failed: got '%s'", record.Get("NSN"))
 	}
 
-	if record["QUAL"] != "NZ2101" {
-		t.Errorf("QUAL extraction failed: got '%s'", record["QUAL"])
+	if record.Get("QUAL") != "NZ2101" {
+		t.Errorf("QUAL extraction failed: got '%s'", record.Get("QUAL"))
 	}
 
-	if record["YR_REQ_MET"] != "2024" {
-		t.Errorf("YR_REQ_MET extraction failed: got '%s'", record["YR_REQ_MET"])
+	if record.Get("YR_REQ_MET") != "2024" {
+		t.Errorf("YR_REQ_MET extraction failed: got '%s'", record.Get("YR_REQ_MET"))
 	}
 }
 
@@ -330,7 +330,7 @@ func TestQUALParser_OptionalFields(t *testing.T) {
 
 	// Optional fields should be empty or have values
 	for _, fieldName := range []string{"MAIN_1", "MAIN_2", "MAIN_3", "PADDING"} {
-		if _, exists := record[fieldName]; !exists {
+		if _, exists := record.Lookup(fieldName); !exists {
 			t.Errorf("Optional field %s should exist in record", fieldName)
 		}
 	}
@@ -6,6 +6,7 @@ func GetQUALSpec() FileSpec {
 		FileType:    "QUAL",
 		Description: "Qualification Completion records",
 		LineLength:  50, // Based on the file specification
+		Key:         []string{"ID", "QUAL", "YR_REQ_MET"},
 		Fields: []FieldSpec{
 			{
 				Name:     "INSTIT",
@@ -13,6 +14,7 @@ func GetQUALSpec() FileSpec {
 				Start:    1,
 				Length:   4,
 				Required: true,
+				Type:     FieldCode,
 			},
 			{
 				Name:     "ID",
@@ -22,11 +24,13 @@ func GetQUALSpec() FileSpec {
 				Required: true,
 			},
 			{
-				Name:     "NSN",
-				Title:    "National Student Number",
-				Start:    15,
-				Length:   10,
-				Required: true,
+				Name:       "NSN",
+				Title:      "National Student Number",
+				Start:      15,
+				Length:     10,
+				Required:   true,
+				Type:       FieldCode,
+				RightAlign: true,
 			},
 			{
 				Name:     "QUAL",
@@ -34,6 +38,7 @@ func GetQUALSpec() FileSpec {
 				Start:    25,
 				Length:   6,
 				Required: true,
+				Type:     FieldCode,
 			},
 			{
 				Name:     "MAIN_1",
@@ -41,6 +46,7 @@ func GetQUALSpec() FileSpec {
 				Start:    31,
 				Length:   4,
 				Required: false,
+				Type:     FieldCode,
 			},
 			{
 				Name:     "MAIN_2",
@@ -48,6 +54,7 @@ func GetQUALSpec() FileSpec {
 				Start:    35,
 				Length:   4,
 				Required: false,
+				Type:     FieldCode,
 			},
 			{
 				Name:     "MAIN_3",
@@ -55,6 +62,7 @@ func GetQUALSpec() FileSpec {
 				Start:    39,
 				Length:   4,
 				Required: false,
+				Type:     FieldCode,
 			},
 			{
 				Name:     "YR_REQ_MET",
@@ -62,6 +70,7 @@ func GetQUALSpec() FileSpec {
 				Start:    43,
 				Length:   4,
 				Required: true,
+				Type:     FieldInteger,
 			},
 			{
 				Name:     "PADDING",
@@ -0,0 +1,96 @@
+package parser
+
+// RecordIndex maps column names to positions in a Record. A parser builds one
+// index and shares it between every record it produces.
+type RecordIndex struct {
+	names     []string
+	positions map[string]int
+}
+
+// NewRecordIndex creates an index over the given column names.
+// Empty names (spacer columns) take a position but cannot be looked up.
+func NewRecordIndex(names []string) *RecordIndex {
+	index := &RecordIndex{
+		names:     names,
+		positions: make(map[string]int, len(names)),
+	}
+	for i, name := range names {
+		if name != "" {
+			index.positions[name] = i
+		}
+	}
+	return index
+}
+
+// Names returns the column names in record order
+func (ix *RecordIndex) Names() []string {
+	return ix.names
+}
+
+// Position returns the position of the named column
+func (ix *RecordIndex) Position(name string) (int, bool) {
+	if ix == nil {
+		return 0, false
+	}
+	i, ok := ix.positions[name]
+	return i, ok
+}
+
+// Record is one parsed line: its values in column order, named through a shared index
+type Record struct {
+	index  *RecordIndex
+	values []string
+}
+
+// NewRecord creates an empty record with a value for every column of the index
+func NewRecord(index *RecordIndex) Record {
+	return Record{index: index, values: make([]string, len(index.names))}
+}
+
+// Get returns the value of the named column, or "" if the record has no such column
+func (r Record) Get(name string) string {
+	value, _ := r.Lookup(name)
+	return value
+}
+
+// Lookup returns the value of the named column and whether the record has that column
+func (r Record) Lookup(name string) (string, bool) {
+	i, ok := r.index.Position(name)
+	if !ok {
+		return "", false
+	}
+	return r.values[i], true
+}
+
+// Set sets the value of the named column. It reports false if the record has no such column.
+func (r Record) Set(name, value string) bool {
+	i, ok := r.index.Position(name)
+	if ok {
+		r.values[i] = value
+	}
+	return ok
+}
+
+// Values returns the values in column order. The slice is the record's own storage.
+func (r Record) Values() []string {
+	return r.values
+}
+
+// Names returns the column names in the order of Values
+func (r Record) Names() []string {
+	if r.index == nil {
+		return nil
+	}
+	return r.index.names
+}
+
+// Map returns the record as a map from column name to value
+func (r Record) Map() map[string]string {
+	m := make(map[string]string, len(r.values))
+	for i, name := range r.Names() {
+		if name != "" {
+			m[name] = r.values[i]
+		}
+	}
+	return m
+}
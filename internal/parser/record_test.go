@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRecord_GetAndLookup(t *testing.T) {
+	records, err := NewFixedWidthParser(testSpec).Parse("1234ALPHAY\n5678     N\n")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if records[0].Get("NAME") != "ALPHA" || records[1].Get("FLAG") != "N" {
+		t.Errorf("Unexpected values: %v, %v", records[0].Map(), records[1].Map())
+	}
+
+	// An empty field is present; an unknown column is not
+	if value, ok := records[1].Lookup("NAME"); !ok || value != "" {
+		t.Errorf("Expected empty NAME, got %q (%v)", value, ok)
+	}
+	if _, ok := records[1].Lookup("COLOUR"); ok {
+		t.Error("Expected no COLOUR column")
+	}
+	if records[1].Set("COLOUR", "red") {
+		t.Error("Expected Set to refuse an unknown column")
+	}
+
+	// Records share one index but not their values
+	if records[0].index != records[1].index {
+		t.Error("Expected records of one parse to share an index")
+	}
+	records[0].Set("NAME", "OMEGA")
+	if records[1].Get("NAME") != "" {
+		t.Errorf("Setting one record changed another: %q", records[1].Get("NAME"))
+	}
+
+	var zero Record
+	if zero.Get("NAME") != "" || len(zero.Values()) != 0 {
+		t.Error("Expected the zero Record to be empty")
+	}
+}
+
+func TestRecord_ValuesFollowColumnNames(t *testing.T) {
+	parser := NewCourseEnrolmentParser()
+	parser.setEnrichment(joinColumns("COUR"), nil)
+
+	records, err := parser.Parse(sniffSamples["COUR"])
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	record := records[0]
+	names := parser.GetColumnNames()
+	if len(record.Values()) != len(names) {
+		t.Fatalf("Expected %d values, got %d", len(names), len(record.Values()))
+	}
+	for i, name := range names {
+		if name != "" && record.Values()[i] != record.Get(name) {
+			t.Errorf("Column %d (%s): value %q does not match Get %q", i, name, record.Values()[i], record.Get(name))
+		}
+	}
+	if record.Get("EFTS_MTH_06") != "0.0114" || record.Get("EFTS_MTH_TOTAL") != "0.0699" || record.Get("NSN") != "120331711" {
+		t.Errorf("Unexpected values: %v", record.Map())
+	}
+}
+
+func BenchmarkCSVWriter_COUR(b *testing.B) {
+	content := strings.Repeat(sniffSamples["COUR"]+"\n", 10000)
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if _, err := NewCSVWriter().writeStream(io.Discard, strings.NewReader(content), NewCourseEnrolmentParser()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
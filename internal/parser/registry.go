@@ -0,0 +1,141 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FileTypeRegistration describes an SDR file type to the processor, the CSV writer and the TUI
+type FileTypeRegistration struct {
+	Spec      FileSpec              // Current field layout of the file
+	Versions  []FileSpec            // Earlier layouts, each with the Year it took effect
+	Hints     []string              // Filename substrings that identify the file type (e.g., "STUD")
+	NewParser func(FileSpec) Parser // Optional constructor; defaults to a FixedWidthParser over the spec
+}
+
+// registry holds registered file types in registration order
+var registry []FileTypeRegistration
+
+func init() {
+	RegisterFileType(FileTypeRegistration{
+		Spec:      GetSTUDSpec(),
+		Hints:     []string{"STUD"},
+		NewParser: func(spec FileSpec) Parser { return &STUDParser{NewFixedWidthParser(spec)} },
+	})
+	RegisterFileType(FileTypeRegistration{
+		Spec:      CourseEnrolmentSpec,
+		Hints:     []string{"COUR"},
+		NewParser: func(spec FileSpec) Parser { return newCourseEnrolmentParser(spec) },
+	})
+	RegisterFileType(FileTypeRegistration{
+		Spec:      GetCREGSpec(),
+		Hints:     []string{"CREG"},
+		NewParser: func(spec FileSpec) Parser { return &CREGParser{NewFixedWidthParser(spec)} },
+	})
+	RegisterFileType(FileTypeRegistration{
+		Spec:      GetCOMPSpec(),
+		Hints:     []string{"COMP"},
+		NewParser: func(spec FileSpec) Parser { return &COMPParser{NewFixedWidthParser(spec)} },
+	})
+	RegisterFileType(FileTypeRegistration{
+		Spec:      GetQUALSpec(),
+		Hints:     []string{"QUAL"},
+		NewParser: func(spec FileSpec) Parser { return &QUALParser{NewFixedWidthParser(spec)} },
+	})
+}
+
+// RegisterFileType adds a file type to the registry.
+// It panics if the file type is already registered, as that is a programming error.
+func RegisterFileType(reg FileTypeRegistration) {
+	fileType := strings.ToUpper(reg.Spec.FileType)
+	if fileType == "" {
+		panic("parser: RegisterFileType called with an empty file type")
+	}
+	if _, exists := LookupFileType(fileType); exists {
+		panic(fmt.Sprintf("parser: file type %s registered twice", fileType))
+	}
+
+	// Fall back to the file type itself as the filename hint
+	if len(reg.Hints) == 0 {
+		reg.Hints = []string{fileType}
+	}
+
+	registry = append(registry, reg)
+}
+
+// UseSpec installs a layout loaded at runtime (see LoadSpecDir). It replaces the
+// registered layout of the same file type and year, becomes the current layout if
+// it is newer than the current one, and is otherwise added as an earlier layout.
+// A file type that is not registered yet is registered with the default hints.
+func UseSpec(spec FileSpec) {
+	i := -1
+	for j, reg := range registry {
+		if strings.EqualFold(reg.Spec.FileType, spec.FileType) {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		RegisterFileType(FileTypeRegistration{Spec: spec})
+		return
+	}
+
+	reg := &registry[i]
+	switch {
+	case spec.Year == reg.Spec.Year:
+		reg.Spec = spec
+	case spec.Year > reg.Spec.Year:
+		reg.Versions = append([]FileSpec{reg.Spec}, reg.Versions...)
+		reg.Spec = spec
+	default:
+		versions := make([]FileSpec, 0, len(reg.Versions)+1)
+		replaced := false
+		for _, version := range reg.Versions {
+			if version.Year == spec.Year {
+				version = spec
+				replaced = true
+			}
+			versions = append(versions, version)
+		}
+		if !replaced {
+			versions = append(versions, spec)
+		}
+		reg.Versions = versions
+	}
+}
+
+// RegisteredFileTypes returns all registered file types in registration order
+func RegisteredFileTypes() []FileTypeRegistration {
+	types := make([]FileTypeRegistration, len(registry))
+	copy(types, registry)
+	return types
+}
+
+// LookupFileType returns the registration for a file type
+func LookupFileType(fileType string) (FileTypeRegistration, bool) {
+	for _, reg := range registry {
+		if strings.EqualFold(reg.Spec.FileType, fileType) {
+			return reg, true
+		}
+	}
+	return FileTypeRegistration{}, false
+}
+
+// MatchesFilename reports whether a filename contains one of the registration's hints
+func (r FileTypeRegistration) MatchesFilename(filename string) bool {
+	upper := strings.ToUpper(filename)
+	for _, hint := range r.Hints {
+		if strings.Contains(upper, strings.ToUpper(hint)) {
+			return true
+		}
+	}
+	return false
+}
+
+// newParser creates a parser for one of the registration's layouts
+func (r FileTypeRegistration) newParser(spec FileSpec) Parser {
+	if r.NewParser != nil {
+		return r.NewParser(spec)
+	}
+	return NewFixedWidthParser(spec)
+}
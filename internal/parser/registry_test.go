@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistry_BuiltInTypes(t *testing.T) {
+	expected := []string{"STUD", "COUR", "CREG", "COMP", "QUAL"}
+
+	types := RegisteredFileTypes()
+	if len(types) < len(expected) {
+		t.Fatalf("Expected at least %d registered types, got %d", len(expected), len(types))
+	}
+
+	for i, fileType := range expected {
+		if types[i].Spec.FileType != fileType {
+			t.Errorf("Registration %d: expected '%s', got '%s'", i, fileType, types[i].Spec.FileType)
+		}
+	}
+}
+
+func TestRegistry_DetectFileType(t *testing.T) {
+	tests := []struct {
+		filename string
+		expected string
+	}{
+		{"STUD9170.txt", "STUD"},
+		{"cour9170.txt", "COUR"},
+		{"CREG9170.TXT", "CREG"},
+		{"COMP9170.txt", "COMP"},
+		{"QUAL9170.txt", "QUAL"},
+		{"export.txt", ""},
+	}
+
+	for _, test := range tests {
+		if actual := DetectFileType(test.filename); actual != test.expected {
+			t.Errorf("DetectFileType(%s): expected '%s', got '%s'", test.filename, test.expected, actual)
+		}
+	}
+}
+
+func TestRegistry_NewFileTypeFromOneRegistration(t *testing.T) {
+	spec := testSpec
+	spec.FileType = "REGT"
+	RegisterFileType(FileTypeRegistration{
+		Spec:  spec,
+		Hints: []string{"REGTEST"},
+	})
+
+	if fileType := DetectFileType("regtest2024.txt"); fileType != "REGT" {
+		t.Fatalf("Expected file type 'REGT', got '%s'", fileType)
+	}
+
+	parser, err := GetParser("REGT")
+	if err != nil {
+		t.Fatalf("GetParser failed: %v", err)
+	}
+
+	records, err := parser.Parse("1234ALPHAY")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "regtest_parsed.csv")
+	if err := NewCSVWriter().WriteCSV(records, parser.GetHeaders(), outputPath, parser); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+
+	rows := readCSV(t, outputPath)
+	if len(rows) != 2 {
+		t.Fatalf("Expected header and 1 row, got %d rows", len(rows))
+	}
+	if rows[0][1] != "Name" || rows[1][1] != "ALPHA" {
+		t.Errorf("Unexpected CSV content: %v", rows)
+	}
+}
+
+func TestRegistry_DuplicateRegistrationPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for duplicate registration")
+		}
+	}()
+
+	RegisterFileType(FileTypeRegistration{Spec: GetSTUDSpec()})
+}
+
+// readCSV reads a CSV file written during a test
+func readCSV(t *testing.T, path string) [][]string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return rows
+}
@@ -0,0 +1,339 @@
+package parser
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report file extensions, one per export format
+const (
+	reportCSVExt  = ".csv"
+	reportJSONExt = ".json"
+	reportHTMLExt = ".html"
+)
+
+// reportColumns is the header of a CSV report
+var reportColumns = []string{"File", "File Type", "Line", "Field", "Rule", "Severity", "Value", "Message"}
+
+// ValidationReport collects the findings of every check over one or more files,
+// for export as CSV, JSON or HTML
+type ValidationReport struct {
+	Created     time.Time
+	Files       []string // Files checked, by base name
+	RecordCount int
+	Findings    []Finding
+}
+
+// RuleSummary counts the findings of one rule
+type RuleSummary struct {
+	Rule     string   `json:"rule"`
+	Title    string   `json:"title,omitempty"`
+	Severity Severity `json:"severity"` // Most serious severity among the findings
+	Count    int      `json:"count"`
+}
+
+// FileSummary counts the findings about one file; File is empty for findings about the whole return
+type FileSummary struct {
+	File     string `json:"file"`
+	Blocking int    `json:"blocking"`
+	Errors   int    `json:"errors"`
+	Warnings int    `json:"warnings"`
+}
+
+// count adds a finding of the given severity
+func (s *FileSummary) count(severity Severity) {
+	switch severity {
+	case SeverityBlocking:
+		s.Blocking++
+	case SeverityError:
+		s.Errors++
+	default:
+		s.Warnings++
+	}
+}
+
+// NewValidationReport creates an empty report
+func NewValidationReport() *ValidationReport {
+	return &ValidationReport{Created: time.Now()}
+}
+
+// AddResult adds the outcome of processing one file: the error that stopped it,
+// lines skipped or worth a look, comparison warnings and validation findings
+func (r *ValidationReport) AddResult(result ProcessorResult) {
+	file := filepath.Base(result.InputFile)
+	r.Files = append(r.Files, file)
+	r.RecordCount += result.RecordCount
+
+	if result.Error != nil {
+		r.addError(file, result.FileType, result.Error)
+	}
+	for _, lineErr := range result.LineErrors {
+		r.Findings = append(r.Findings, lineFinding("parse", SeverityError, file, result.FileType, lineErr))
+	}
+	for _, warning := range result.Warnings {
+		r.Findings = append(r.Findings, lineFinding("line-warning", SeverityWarning, file, result.FileType, warning))
+	}
+	for _, warning := range result.ComparisonWarnings {
+		r.Findings = append(r.Findings, Finding{
+			Rule:     "comparison",
+			Severity: SeverityWarning,
+			FileType: result.FileType,
+			File:     file,
+			Message:  warning,
+		})
+	}
+	r.Findings = append(r.Findings, result.Findings...)
+}
+
+// AddReturn adds the outcome of validating a return
+func (r *ValidationReport) AddReturn(result ReturnResult) {
+	for _, path := range result.Files {
+		r.Files = append(r.Files, filepath.Base(path))
+	}
+	r.RecordCount += result.RecordCount
+	if result.Error != nil {
+		r.addError("", "", result.Error)
+	}
+	r.Findings = append(r.Findings, result.Findings...)
+}
+
+// addError adds an error that stopped a file or return from being checked
+func (r *ValidationReport) addError(file, fileType string, err error) {
+	finding := Finding{
+		Rule:     "file-error",
+		Severity: SeverityError,
+		FileType: fileType,
+		File:     file,
+		Message:  err.Error(),
+	}
+	var lineErr *LineError
+	if errors.As(err, &lineErr) {
+		finding.Line = lineErr.Line
+		finding.Field = lineErr.Field
+	}
+	r.Findings = append(r.Findings, finding)
+}
+
+// lineFinding turns a line error or warning into a finding
+func lineFinding(rule string, severity Severity, file, fileType string, lineErr LineError) Finding {
+	return Finding{
+		Rule:     rule,
+		Severity: severity,
+		FileType: fileType,
+		File:     file,
+		Line:     lineErr.Line,
+		Field:    lineErr.Field,
+		Message:  lineErr.Reason,
+	}
+}
+
+// Totals counts the findings of the report by severity
+func (r *ValidationReport) Totals() FileSummary {
+	var totals FileSummary
+	for _, finding := range r.Findings {
+		totals.count(finding.Severity)
+	}
+	return totals
+}
+
+// ByRule counts the findings of each rule, in order of each rule's first finding
+func (r *ValidationReport) ByRule() []RuleSummary {
+	groups := GroupFindings(r.Findings, 0)
+	summaries := make([]RuleSummary, len(groups))
+	for i, group := range groups {
+		summaries[i] = RuleSummary{Rule: group.Rule, Title: group.Title, Severity: group.Severity, Count: group.Count}
+	}
+	return summaries
+}
+
+// ByFile counts the findings about each file checked, files without findings
+// included, followed by files only named in findings and then the whole return
+func (r *ValidationReport) ByFile() []FileSummary {
+	var summaries []FileSummary
+	positions := make(map[string]int) // File -> summary
+	add := func(file string) int {
+		i, ok := positions[file]
+		if !ok {
+			i = len(summaries)
+			positions[file] = i
+			summaries = append(summaries, FileSummary{File: file})
+		}
+		return i
+	}
+
+	for _, file := range r.Files {
+		add(file)
+	}
+	for _, finding := range r.Findings {
+		if finding.File != "" {
+			add(finding.File)
+		}
+	}
+	for _, finding := range r.Findings {
+		summaries[add(finding.File)].count(finding.Severity)
+	}
+	return summaries
+}
+
+// WriteCSV writes one row per finding, for sorting and filtering in Excel
+func (r *ValidationReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write(reportColumns)
+	for _, finding := range r.Findings {
+		line := ""
+		if finding.Line > 0 {
+			line = strconv.Itoa(finding.Line)
+		}
+		writer.Write([]string{
+			finding.File,
+			finding.FileType,
+			line,
+			finding.Field,
+			finding.Rule,
+			string(finding.Severity),
+			finding.Value,
+			finding.Message,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// reportJSON is the layout of a JSON report
+type reportJSON struct {
+	Created     time.Time     `json:"created"`
+	Files       []string      `json:"files"`
+	RecordCount int           `json:"record_count"`
+	Blocking    int           `json:"blocking"`
+	Errors      int           `json:"errors"`
+	Warnings    int           `json:"warnings"`
+	Rules       []RuleSummary `json:"rules"`
+	ByFile      []FileSummary `json:"by_file"`
+	Findings    []Finding     `json:"findings"`
+}
+
+// WriteJSON writes the report, its summary counts and every finding as JSON, for automation
+func (r *ValidationReport) WriteJSON(w io.Writer) error {
+	report := reportJSON{
+		Created:     r.Created,
+		Files:       append([]string{}, r.Files...),
+		RecordCount: r.RecordCount,
+		Rules:       append([]RuleSummary{}, r.ByRule()...),
+		ByFile:      append([]FileSummary{}, r.ByFile()...),
+		Findings:    append([]Finding{}, r.Findings...),
+	}
+	totals := r.Totals()
+	report.Blocking, report.Errors, report.Warnings = totals.Blocking, totals.Errors, totals.Warnings
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// reportPage is a self-contained HTML page: the styles are inline and nothing is loaded
+var reportPage = template.Must(template.New("report").Funcs(template.FuncMap{
+	"where": func(file string) string {
+		if file == "" {
+			return "(whole return)"
+		}
+		return file
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>SDR validation report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #eee; }
+td.number { text-align: right; }
+.blocking { color: #fff; background: #b00020; font-weight: bold; }
+.error { color: #b00020; font-weight: bold; }
+.warning { color: #a65e00; }
+</style>
+</head>
+<body>
+<h1>SDR validation report</h1>
+<p>Created {{.Created.Format "2 January 2006 15:04"}}. {{len .Files}} file(s), {{.RecordCount}} record(s):
+{{if .Totals.Blocking}}<span class="blocking">{{.Totals.Blocking}} blocking</span> (fix before uploading), {{end}}<span class="error">{{.Totals.Errors}} error(s)</span>, <span class="warning">{{.Totals.Warnings}} warning(s)</span>.</p>
+
+<h2>Findings by rule</h2>
+{{if .Rules}}<table>
+<tr><th>Rule</th><th>Description</th><th>Severity</th><th>Findings</th></tr>
+{{range .Rules}}<tr><td>{{.Rule}}</td><td>{{.Title}}</td><td class="{{.Severity}}">{{.Severity}}</td><td class="number">{{.Count}}</td></tr>
+{{end}}</table>
+{{else}}<p>No findings.</p>
+{{end}}
+<h2>Findings by file</h2>
+<table>
+<tr><th>File</th><th>Blocking</th><th>Errors</th><th>Warnings</th></tr>
+{{range .ByFile}}<tr><td>{{where .File}}</td><td class="number">{{.Blocking}}</td><td class="number">{{.Errors}}</td><td class="number">{{.Warnings}}</td></tr>
+{{end}}</table>
+{{if .Findings}}
+<h2>Findings</h2>
+<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Findings}}<tr><td>{{where .File}}</td><td>{{.FileType}}</td><td class="number">{{if .Line}}{{.Line}}{{end}}</td><td>{{.Field}}</td><td>{{.Rule}}</td><td class="{{.Severity}}">{{.Severity}}</td><td>{{.Value}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+// WriteHTML writes the report as a self-contained web page with counts per rule and per file
+func (r *ValidationReport) WriteHTML(w io.Writer) error {
+	return reportPage.Execute(w, struct {
+		*ValidationReport
+		Totals  FileSummary
+		Rules   []RuleSummary
+		ByFile  []FileSummary
+		Columns []string
+	}{r, r.Totals(), r.ByRule(), r.ByFile(), reportColumns})
+}
+
+// WriteFile writes the report to a file in the format named by its extension (.csv, .json or .html)
+func (r *ValidationReport) WriteFile(path string) error {
+	var write func(io.Writer) error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case reportCSVExt:
+		write = r.WriteCSV
+	case reportJSONExt:
+		write = r.WriteJSON
+	case reportHTMLExt:
+		write = r.WriteHTML
+	default:
+		return fmt.Errorf("unknown report format %q (use .csv, .json or .html)", filepath.Ext(path))
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := errors.Join(write(file), file.Close()); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// Save writes the report to <name>.csv, <name>.json and <name>.html in dir. It returns the paths written.
+func (r *ValidationReport) Save(dir, name string) ([]string, error) {
+	var paths []string
+	for _, ext := range []string{reportCSVExt, reportJSONExt, reportHTMLExt} {
+		path := filepath.Join(dir, name+ext)
+		if err := r.WriteFile(path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
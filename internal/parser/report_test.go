@@ -0,0 +1,141 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidationReport_AddResult(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "COUR9170.txt")
+
+	// Line 2 has FUNDING "XX"; there is no COMP file to compare with
+	line := sniffSamples["COUR"]
+	bad := withField(CourseEnrolmentSpec, line, "FUNDING", "XX")
+	bad = withField(CourseEnrolmentSpec, bad, "ID", "917000048")
+	if err := os.WriteFile(inputPath, []byte(line+"\n"+bad+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	useTestJoins(t, builtInJoins[0]) // COMP onto COUR only
+	result := ProcessFileWithOptions(inputPath, dir, ProcessOptions{EnableComparison: true, Validate: true})
+	if !result.Success {
+		t.Fatalf("Expected success, got %v", result.Error)
+	}
+	if len(result.ComparisonWarnings) != 1 || !strings.Contains(result.ComparisonWarnings[0], "No COMP file") {
+		t.Fatalf("Expected a warning about the missing COMP file, got %v", result.ComparisonWarnings)
+	}
+
+	report := NewValidationReport()
+	report.AddResult(result)
+	report.AddResult(ProcessorResult{InputFile: filepath.Join(dir, "STUD9170.txt"), Error: &LineError{Line: 3, Field: "DOB", Reason: "bad date"}})
+
+	if len(report.Files) != 2 || report.Files[0] != "COUR9170.txt" || report.RecordCount != 2 {
+		t.Errorf("Unexpected files %v and record count %d", report.Files, report.RecordCount)
+	}
+	if totals := report.Totals(); totals != (FileSummary{Errors: 2, Warnings: 1}) {
+		t.Errorf("Expected 2 errors and 1 warning, got %+v", totals)
+	}
+
+	rules := report.ByRule()
+	if len(rules) != 3 || rules[0].Rule != "comparison" || rules[1].Rule != "code-set" || rules[2].Rule != "file-error" {
+		t.Fatalf("Unexpected rules: %+v", rules)
+	}
+	if rules[1].Count != 1 || rules[1].Severity != SeverityError || rules[1].Title == "" {
+		t.Errorf("Unexpected code-set summary: %+v", rules[1])
+	}
+
+	stopped := findingsByRule(report.Findings, "file-error")[0]
+	if stopped.File != "STUD9170.txt" || stopped.Line != 3 || stopped.Field != "DOB" {
+		t.Errorf("Expected the error's line and field, got %+v", stopped)
+	}
+
+	expected := []FileSummary{{File: "COUR9170.txt", Errors: 1, Warnings: 1}, {File: "STUD9170.txt", Errors: 1}}
+	if files := report.ByFile(); len(files) != 2 || files[0] != expected[0] || files[1] != expected[1] {
+		t.Errorf("Expected %+v, got %+v", expected, files)
+	}
+}
+
+func TestValidationReport_Export(t *testing.T) {
+	report := NewValidationReport()
+	report.AddReturn(ReturnResult{Files: []string{"/tmp/STUD9170.txt", "/tmp/COUR9170.txt"}, RecordCount: 5})
+	report.Findings = append(report.Findings,
+		Finding{Rule: "code-set", Severity: SeverityError, FileType: "COUR", File: "COUR9170.txt", Line: 2,
+			Field: "FUNDING", Value: "XX", Message: `"XX" is not in code set FUNDING <01, 02>`},
+		Finding{Rule: "missing-file", Severity: SeverityWarning, FileType: "QUAL", Message: "the return has no QUAL file"},
+	)
+
+	var out bytes.Buffer
+	if err := report.WriteCSV(&out); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV report: %v", err)
+	}
+	if len(rows) != 3 || strings.Join(rows[0], ",") != "File,File Type,Line,Field,Rule,Severity,Value,Message" {
+		t.Fatalf("Unexpected CSV report: %v", rows)
+	}
+	if rows[1][2] != "2" || rows[1][6] != "XX" || rows[2][2] != "" {
+		t.Errorf("Unexpected CSV rows: %v", rows[1:])
+	}
+
+	out.Reset()
+	if err := report.WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var decoded struct {
+		Files    []string
+		Errors   int
+		Warnings int
+		ByFile   []FileSummary `json:"by_file"`
+		Findings []Finding
+	}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to read JSON report: %v", err)
+	}
+	if len(decoded.Files) != 2 || decoded.Errors != 1 || decoded.Warnings != 1 || len(decoded.Findings) != 2 {
+		t.Errorf("Unexpected JSON report: %+v", decoded)
+	}
+	if decoded.Findings[0] != report.Findings[0] {
+		t.Errorf("Expected %+v, got %+v", report.Findings[0], decoded.Findings[0])
+	}
+	// STUD has no findings; the missing file is about the whole return
+	if len(decoded.ByFile) != 3 || decoded.ByFile[0] != (FileSummary{File: "STUD9170.txt"}) || decoded.ByFile[2] != (FileSummary{Warnings: 1}) {
+		t.Errorf("Unexpected counts by file: %+v", decoded.ByFile)
+	}
+
+	out.Reset()
+	if err := report.WriteHTML(&out); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	page := out.String()
+	for _, expected := range []string{"&lt;01, 02&gt;", "(whole return)", ruleTitle("missing-file"), "1 error(s)"} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("Expected the HTML report to contain %q", expected)
+		}
+	}
+	if strings.Contains(page, "<01, 02>") || strings.Contains(page, "<script") || strings.Contains(page, "<link") {
+		t.Error("Expected an escaped, self-contained HTML report")
+	}
+
+	dir := t.TempDir()
+	paths, err := report.Save(dir, "validation_report")
+	if err != nil || len(paths) != 3 {
+		t.Fatalf("Expected 3 report files, got %v (%v)", paths, err)
+	}
+	for _, path := range paths {
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Errorf("Expected %s to be written", path)
+		}
+	}
+	if err := report.WriteFile(filepath.Join(dir, "report.xlsx")); err == nil || errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected an unknown format error, got %v", err)
+	}
+}
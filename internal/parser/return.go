@@ -0,0 +1,625 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Plausible student ages at the start of a course; outside them DOB is probably mistyped
+const (
+	minStudentAge = 12
+	maxStudentAge = 100
+)
+
+// returnFileTypes are the file types of a complete return
+var returnFileTypes = []string{"STUD", "CREG", "COUR", "COMP", "QUAL"}
+
+// Return is an SDR return: the files of one submission, at most one per file type
+type Return struct {
+	Files []*ReturnFile // In file type registration order
+}
+
+// ReturnFile is one parsed file of a return
+type ReturnFile struct {
+	Path       string
+	FileType   string
+	Year       int // Collection year whose layout was used, 0 if unknown
+	Spec       FileSpec
+	Records    []Record
+	Sources    []Source    // Where each record came from, in the same order (without the raw line)
+	LineErrors []LineError // Lines that could not be parsed
+}
+
+// ReturnResult is the outcome of validating a return
+type ReturnResult struct {
+	Files       []string // Files validated, in file type order
+	RecordCount int
+	Findings    []Finding
+	Error       error
+}
+
+// Blocked reports whether a finding must be fixed before the return is uploaded
+func (r ReturnResult) Blocked() bool {
+	for _, finding := range r.Findings {
+		if finding.Severity == SeverityBlocking {
+			return true
+		}
+	}
+	return false
+}
+
+// returnCheck is a check that needs more than one file of a return
+type returnCheck func(r *Return) []Finding
+
+// returnChecks are run by Return.Validate after the record checks
+var returnChecks = []returnCheck{
+	checkProviderCodes,
+	checkMissingFiles,
+	checkStudentsExist,
+	checkCoursesRegistered,
+	checkCompletionEnrolments,
+	checkQualificationEnrolments,
+	checkCourseFactors,
+	checkStudentAges,
+	checkCompletionDates,
+	checkJoinedRules,
+}
+
+// File returns the file of the given type, or nil if the return has none
+func (r *Return) File(fileType string) *ReturnFile {
+	for _, file := range r.Files {
+		if file.FileType == fileType {
+			return file
+		}
+	}
+	return nil
+}
+
+// LoadReturn detects and parses SDR files as one return. Bad lines are skipped and
+// kept as LineErrors, so the checks can still run over the rest of the file.
+func LoadReturn(paths []string, opts ProcessOptions) (*Return, error) {
+	byType := make(map[string]*ReturnFile)
+	for _, path := range paths {
+		file, err := loadReturnFile(path, opts)
+		if err != nil {
+			return nil, err
+		}
+		if other, exists := byType[file.FileType]; exists {
+			return nil, fmt.Errorf("%s and %s are both %s files; a return has one file of each type",
+				filepath.Base(other.Path), filepath.Base(path), file.FileType)
+		}
+		byType[file.FileType] = file
+	}
+
+	ret := &Return{}
+	for _, reg := range RegisteredFileTypes() {
+		if file, ok := byType[reg.Spec.FileType]; ok {
+			ret.Files = append(ret.Files, file)
+		}
+	}
+	return ret, nil
+}
+
+// loadReturnFile detects the layout of one file and parses all of it
+func loadReturnFile(path string, opts ProcessOptions) (*ReturnFile, error) {
+	layout, err := detectLayout(path, opts.Year)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &ReturnFile{
+		Path:     path,
+		FileType: layout.detection.FileType,
+		Year:     layout.year,
+		Spec:     layout.spec,
+	}
+
+	parser, err := GetParserForYear(file.FileType, file.Year)
+	if err != nil {
+		return nil, err
+	}
+	parser.SetOptions(ParseOptions{
+		Lenient:    true,
+		Strict:     opts.Strict,
+		SourceName: filepath.Base(path),
+		Encoding:   opts.Encoding,
+	})
+
+	input, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+	defer input.Close()
+
+	err = parser.ParseReader(input, func(record Record, src Source) error {
+		src.Raw = "" // Not needed by the checks, and would double the memory used
+		file.Records = append(file.Records, record)
+		file.Sources = append(file.Sources, src)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+	}
+	file.LineErrors = parser.GetLineErrors()
+	return file, nil
+}
+
+// ValidateReturn loads SDR files as one return and runs every check over it
+func ValidateReturn(paths []string, opts ProcessOptions) ReturnResult {
+	if len(paths) == 0 {
+		return ReturnResult{Error: errors.New("no SDR files to validate")}
+	}
+
+	ret, err := LoadReturn(paths, opts)
+	if err != nil {
+		return ReturnResult{Error: err}
+	}
+
+	var result ReturnResult
+	for _, file := range ret.Files {
+		result.Files = append(result.Files, file.Path)
+		result.RecordCount += len(file.Records)
+	}
+	result.Findings = ret.Validate()
+	return result
+}
+
+// Validate reports the lines that could not be parsed, runs the record checks over
+// every file and then the checks across files
+func (r *Return) Validate() []Finding {
+	var findings []Finding
+	for _, file := range r.Files {
+		for _, lineErr := range file.LineErrors {
+			findings = append(findings, Finding{
+				Rule:     "parse",
+				Severity: SeverityError,
+				FileType: file.FileType,
+				File:     filepath.Base(file.Path),
+				Line:     lineErr.Line,
+				Field:    lineErr.Field,
+				Message:  lineErr.Reason,
+			})
+		}
+
+		validator := NewValidator(file.Spec)
+		validator.SetYear(file.Year)
+		for i, record := range file.Records {
+			validator.Check(record, file.Sources[i])
+		}
+		findings = append(findings, validator.Findings()...)
+	}
+
+	for _, check := range returnChecks {
+		findings = append(findings, check(r)...)
+	}
+	return findings
+}
+
+// finding creates a finding about the i'th record of the file
+func (f *ReturnFile) finding(i int, rule string, severity Severity, field, message string) Finding {
+	return Finding{
+		Rule:     rule,
+		Severity: severity,
+		FileType: f.FileType,
+		File:     f.Sources[i].File,
+		Line:     f.Sources[i].Line,
+		Field:    field,
+		Value:    f.Records[i].Get(field),
+		Message:  message,
+	}
+}
+
+// date returns the named date field of the i'th record, if it is set
+func (f *ReturnFile) date(i int, name string) (time.Time, bool) {
+	if f.Records[i].Get(name) == "" {
+		return time.Time{}, false
+	}
+	date, err := f.Spec.Date(f.Records[i], name)
+	return date, err == nil
+}
+
+// joinKey joins the values of a composite key with a separator that does not occur in SDR data
+func joinKey(values ...string) string {
+	return strings.Join(values, "||")
+}
+
+// checkStudentAges reports students whose DOB makes them implausibly young or old
+// at the start of one of their COUR enrolments, once per student
+func checkStudentAges(r *Return) []Finding {
+	stud, cour := r.File("STUD"), r.File("COUR")
+	if stud == nil || cour == nil {
+		return nil
+	}
+
+	students := make(map[string]int) // ID -> STUD record
+	for i, record := range stud.Records {
+		if _, exists := students[record.Get("ID")]; !exists {
+			students[record.Get("ID")] = i
+		}
+	}
+
+	var findings []Finding
+	reported := make(map[string]bool)
+	for i, record := range cour.Records {
+		id := record.Get("ID")
+		s, ok := students[id]
+		if !ok || reported[id] {
+			continue
+		}
+		dob, ok := stud.date(s, "DOB")
+		if !ok {
+			continue
+		}
+		start, ok := cour.date(i, "CRS_SRT")
+		if !ok {
+			continue
+		}
+
+		age := ageOn(dob, start)
+		if age >= minStudentAge && age <= maxStudentAge {
+			continue
+		}
+		reported[id] = true
+		findings = append(findings, stud.finding(s, "student-age", SeverityWarning, "DOB",
+			fmt.Sprintf("student %s would be %d at the start of course %s on %s (COUR line %d); expected %d to %d",
+				id, age, record.Get("COURSE"), record.Get("CRS_SRT"), cour.Sources[i].Line, minStudentAge, maxStudentAge)))
+	}
+	return findings
+}
+
+// ageOn returns the age in whole years on a date of someone born on dob
+func ageOn(dob, date time.Time) int {
+	age := date.Year() - dob.Year()
+	if date.Month() < dob.Month() || (date.Month() == dob.Month() && date.Day() < dob.Day()) {
+		age--
+	}
+	return age
+}
+
+// enrolmentIndex returns the first COUR record of each ID+COURSE+CRS_SRT enrolment
+func enrolmentIndex(cour *ReturnFile) map[string]int {
+	enrolments := make(map[string]int)
+	for i, record := range cour.Records {
+		key := joinKey(record.Get("ID"), record.Get("COURSE"), record.Get("CRS_SRT"))
+		if _, exists := enrolments[key]; !exists {
+			enrolments[key] = i
+		}
+	}
+	return enrolments
+}
+
+// checkCompletionDates reports COMP rows whose end date does not match the COUR
+// enrolment they complete
+func checkCompletionDates(r *Return) []Finding {
+	comp, cour := r.File("COMP"), r.File("COUR")
+	if comp == nil || cour == nil {
+		return nil
+	}
+
+	enrolments := enrolmentIndex(cour)
+	var findings []Finding
+	for i, record := range comp.Records {
+		e, ok := enrolments[joinKey(record.Get("ID"), record.Get("COURSE"), record.Get("CRS_SRT"))]
+		if !ok {
+			continue // Reported by checkCompletionEnrolments
+		}
+
+		if end := cour.Records[e].Get("CRS_END"); end != record.Get("CRS_END") {
+			findings = append(findings, comp.finding(i, "completion-dates", SeverityError, "CRS_END",
+				fmt.Sprintf("course end %s does not match the COUR enrolment (%s, COUR line %d)",
+					record.Get("CRS_END"), end, cour.Sources[e].Line)))
+		}
+	}
+	return findings
+}
+
+// filenameNumber matches a number standing alone in a file name, such as 9170 in COUR9170_2024.txt
+var filenameNumber = regexp.MustCompile(`(?:^|[^0-9])([0-9]+)`)
+
+// filenameYear matches a number that reads as a year (e.g., 2024 in COUR2024_9170.txt)
+var filenameYear = regexp.MustCompile(`^(19|20)[0-9]{2}$`)
+
+// providerOf returns the provider code in the name of a file, when the name starts with its
+// file type: the one number after the type that is as long as INSTIT and is not a year.
+// A name with no such number, or more than one, has no provider code; the findings block
+// the upload, so a name that cannot be read with certainty is not checked.
+func providerOf(file *ReturnFile) (string, bool) {
+	name := strings.TrimSuffix(strings.ToUpper(filepath.Base(file.Path)), strings.ToUpper(filepath.Ext(file.Path)))
+	rest, ok := strings.CutPrefix(name, file.FileType)
+	if !ok {
+		return "", false // Renamed; the type was detected from the content
+	}
+	instit, ok := file.Spec.Field("INSTIT")
+	if !ok {
+		return "", false
+	}
+
+	var codes []string
+	for _, match := range filenameNumber.FindAllStringSubmatch(rest, -1) {
+		if number := match[1]; len(number) == instit.Length && !filenameYear.MatchString(number) {
+			codes = append(codes, number)
+		}
+	}
+	if len(codes) != 1 {
+		return "", false
+	}
+	return codes[0], true
+}
+
+// providerRecords is the records of a file with one INSTIT value
+type providerRecords struct {
+	code  string
+	first int // Index of the first record
+	count int
+}
+
+// checkProviderCodes reports a file whose INSTIT values differ from the provider code in
+// its name, a file with records for more than one provider, and a return whose files are
+// for different providers. All of them block the upload: TEC would credit the wrong provider.
+func checkProviderCodes(r *Return) []Finding {
+	var findings []Finding
+	var providers []string               // Provider of each file that has one, in file order
+	filesOf := make(map[string][]string) // Provider -> file names
+	for _, file := range r.Files {
+		name := filepath.Base(file.Path)
+
+		var codes []*providerRecords
+		byCode := make(map[string]*providerRecords)
+		for i, record := range file.Records {
+			code := record.Get("INSTIT")
+			if code == "" {
+				continue
+			}
+			if byCode[code] == nil {
+				byCode[code] = &providerRecords{code: code, first: i}
+				codes = append(codes, byCode[code])
+			}
+			byCode[code].count++
+		}
+
+		provider, named := providerOf(file)
+		if named {
+			for _, c := range codes {
+				if c.code != provider {
+					findings = append(findings, file.finding(c.first, "provider-file", SeverityBlocking, "INSTIT",
+						fmt.Sprintf("INSTIT %s on %d record(s) does not match provider code %s in the file name", c.code, c.count, provider)))
+				}
+			}
+		}
+		if len(codes) > 1 {
+			var listed []string
+			for _, c := range codes {
+				listed = append(listed, fmt.Sprintf("%s (%d record(s) from line %d)", c.code, c.count, file.Sources[c.first].Line))
+			}
+			findings = append(findings, Finding{
+				Rule:     "provider-mixed",
+				Severity: SeverityBlocking,
+				FileType: file.FileType,
+				File:     name,
+				Field:    "INSTIT",
+				Message:  fmt.Sprintf("the file has records for %d providers: %s", len(codes), strings.Join(listed, ", ")),
+			})
+		}
+
+		if !named {
+			if len(codes) != 1 {
+				continue // No single provider to compare with the other files
+			}
+			provider = codes[0].code
+		}
+		if filesOf[provider] == nil {
+			providers = append(providers, provider)
+		}
+		filesOf[provider] = append(filesOf[provider], name)
+	}
+
+	if len(providers) > 1 {
+		var listed []string
+		for _, provider := range providers {
+			listed = append(listed, fmt.Sprintf("%s (%s)", provider, strings.Join(filesOf[provider], ", ")))
+		}
+		findings = append(findings, Finding{
+			Rule:     "provider-return",
+			Severity: SeverityBlocking,
+			Field:    "INSTIT",
+			Message:  fmt.Sprintf("the files are for %d providers: %s", len(providers), strings.Join(listed, "; ")),
+		})
+	}
+	return findings
+}
+
+// checkMissingFiles reports the file types a return has no file for, since the
+// checks that link to them cannot run
+func checkMissingFiles(r *Return) []Finding {
+	var findings []Finding
+	for _, fileType := range returnFileTypes {
+		if r.File(fileType) != nil {
+			continue
+		}
+		findings = append(findings, Finding{
+			Rule:     "missing-file",
+			Severity: SeverityWarning,
+			FileType: fileType,
+			Message:  fmt.Sprintf("the return has no %s file; checks against it were skipped", fileType),
+		})
+	}
+	return findings
+}
+
+// checkStudentsExist reports COUR enrolments of students that are not in STUD
+func checkStudentsExist(r *Return) []Finding {
+	stud, cour := r.File("STUD"), r.File("COUR")
+	if stud == nil || cour == nil {
+		return nil
+	}
+
+	students := make(map[string]bool)
+	for _, record := range stud.Records {
+		students[record.Get("ID")] = true
+	}
+
+	var findings []Finding
+	for i, record := range cour.Records {
+		if !students[record.Get("ID")] {
+			findings = append(findings, cour.finding(i, "cour-student", SeverityError, "ID",
+				fmt.Sprintf("student %s is not in STUD", record.Get("ID"))))
+		}
+	}
+	return findings
+}
+
+// checkCoursesRegistered reports COUR enrolments whose course is not registered in
+// CREG for the enrolment's qualification
+func checkCoursesRegistered(r *Return) []Finding {
+	creg, cour := r.File("CREG"), r.File("COUR")
+	if creg == nil || cour == nil {
+		return nil
+	}
+
+	registered := make(map[string]bool) // COURSE+QUAL
+	quals := make(map[string][]string)  // COURSE -> qualifications it is registered for
+	for _, record := range creg.Records {
+		key := joinKey(record.Get("COURSE"), record.Get("QUAL"))
+		if !registered[key] {
+			registered[key] = true
+			quals[record.Get("COURSE")] = append(quals[record.Get("COURSE")], record.Get("QUAL"))
+		}
+	}
+
+	var findings []Finding
+	for i, record := range cour.Records {
+		course, qual := record.Get("COURSE"), record.Get("QUAL")
+		if registered[joinKey(course, qual)] {
+			continue
+		}
+		message := fmt.Sprintf("course %s is not in CREG", course)
+		if others := quals[course]; len(others) > 0 {
+			message = fmt.Sprintf("course %s is registered in CREG for %s, not %s", course, strings.Join(others, ", "), qual)
+		}
+		findings = append(findings, cour.finding(i, "cour-course", SeverityError, "COURSE", message))
+	}
+	return findings
+}
+
+// checkCompletionEnrolments reports COMP rows with no COUR enrolment on the same
+// ID, COURSE and CRS_SRT
+func checkCompletionEnrolments(r *Return) []Finding {
+	comp, cour := r.File("COMP"), r.File("COUR")
+	if comp == nil || cour == nil {
+		return nil
+	}
+
+	enrolments := enrolmentIndex(cour)
+	starts := make(map[string][]string) // ID+COURSE -> COUR start dates
+	for _, record := range cour.Records {
+		course := joinKey(record.Get("ID"), record.Get("COURSE"))
+		starts[course] = append(starts[course], record.Get("CRS_SRT"))
+	}
+
+	var findings []Finding
+	for i, record := range comp.Records {
+		id, course, start := record.Get("ID"), record.Get("COURSE"), record.Get("CRS_SRT")
+		if _, ok := enrolments[joinKey(id, course, start)]; ok {
+			continue
+		}
+
+		// An enrolment in the course with another start date is most likely a mistyped date
+		if dates := starts[joinKey(id, course)]; len(dates) > 0 {
+			findings = append(findings, comp.finding(i, "comp-enrolment", SeverityError, "CRS_SRT",
+				fmt.Sprintf("no COUR enrolment of student %s in %s starts on %s (COUR start dates: %s)",
+					id, course, start, strings.Join(dates, ", "))))
+			continue
+		}
+		findings = append(findings, comp.finding(i, "comp-enrolment", SeverityError, "COURSE",
+			fmt.Sprintf("student %s has no COUR enrolment in %s", id, course)))
+	}
+	return findings
+}
+
+// checkQualificationEnrolments reports QUAL completions by students with no COUR
+// enrolment in the qualification
+func checkQualificationEnrolments(r *Return) []Finding {
+	qual, cour := r.File("QUAL"), r.File("COUR")
+	if qual == nil || cour == nil {
+		return nil
+	}
+
+	enrolled := make(map[string]bool) // ID+QUAL
+	for _, record := range cour.Records {
+		enrolled[joinKey(record.Get("ID"), record.Get("QUAL"))] = true
+	}
+
+	var findings []Finding
+	for i, record := range qual.Records {
+		if !enrolled[joinKey(record.Get("ID"), record.Get("QUAL"))] {
+			findings = append(findings, qual.finding(i, "qual-enrolment", SeverityError, "QUAL",
+				fmt.Sprintf("student %s completed %s but has no COUR enrolment in it", record.Get("ID"), record.Get("QUAL"))))
+		}
+	}
+	return findings
+}
+
+// checkCourseFactors reports COUR FACTORs that differ from the CREG FACTOR of the course,
+// once for each course and COUR FACTOR, on the first enrolment with it
+func checkCourseFactors(r *Return) []Finding {
+	creg, cour := r.File("CREG"), r.File("COUR")
+	if creg == nil || cour == nil {
+		return nil
+	}
+
+	factors := make(map[string]int) // INSTIT+COURSE -> CREG record
+	for i, record := range creg.Records {
+		key := joinKey(record.Get("INSTIT"), record.Get("COURSE"))
+		if _, exists := factors[key]; !exists {
+			factors[key] = i
+		}
+	}
+
+	type mismatch struct {
+		first, count int
+		message      string
+	}
+	mismatches := make(map[string]*mismatch) // INSTIT+COURSE+FACTOR
+	var order []string
+	for i, record := range cour.Records {
+		c, ok := factors[joinKey(record.Get("INSTIT"), record.Get("COURSE"))]
+		if !ok || record.Get("FACTOR") == "" {
+			continue // An unregistered course is reported by checkCoursesRegistered
+		}
+		courFactor, err := cour.Spec.Decimal(record, "FACTOR")
+		if err != nil {
+			continue
+		}
+		cregFactor, err := creg.Spec.Decimal(creg.Records[c], "FACTOR")
+		if err != nil || math.Abs(courFactor-cregFactor) < eftsTolerance/10 {
+			continue
+		}
+
+		key := joinKey(record.Get("INSTIT"), record.Get("COURSE"), record.Get("FACTOR"))
+		if m, exists := mismatches[key]; exists {
+			m.count++
+			continue
+		}
+		mismatches[key] = &mismatch{first: i, count: 1, message: fmt.Sprintf(
+			"FACTOR %.4f differs from the CREG FACTOR %.4f of course %s (CREG line %d; difference %+.4f)",
+			courFactor, cregFactor, record.Get("COURSE"), creg.Sources[c].Line, courFactor-cregFactor)}
+		order = append(order, key)
+	}
+
+	var findings []Finding
+	for _, key := range order {
+		m := mismatches[key]
+		message := m.message
+		if m.count > 1 {
+			message += fmt.Sprintf(" on %d enrolments", m.count)
+		}
+		findings = append(findings, cour.finding(m.first, "efts-factor", SeverityError, "FACTOR", message))
+	}
+	return findings
+}
@@ -0,0 +1,274 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withField returns an ASCII sample line, padded to the spec's line length, with one field replaced
+func withField(spec FileSpec, line, name, value string) string {
+	field, ok := spec.Field(name)
+	if !ok {
+		panic("no field " + name)
+	}
+	line += strings.Repeat(" ", max(0, spec.LineLength-len(line)))
+	return line[:field.Start-1] + alignValue(field, value) + line[field.Start-1+field.Length:]
+}
+
+// writeReturn writes SDR files into a temporary directory and returns their paths
+func writeReturn(t *testing.T, files map[string]string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// findingsByRule returns the findings raised by one rule
+func findingsByRule(findings []Finding, rule string) []Finding {
+	var matched []Finding
+	for _, finding := range findings {
+		if finding.Rule == rule {
+			matched = append(matched, finding)
+		}
+	}
+	return matched
+}
+
+func TestValidateReturn_Chronology(t *testing.T) {
+	studSpec := GetSTUDSpec()
+	stud := withField(studSpec, strings.Split(sampleSTUDData, "\n")[0], "INSTIT", "9170")
+	stud = withField(studSpec, stud, "ID", "917000047")
+	young := withField(studSpec, stud, "DOB", "01012020") // Three years old at the course start
+	young = withField(studSpec, young, "ID", "917000048")
+
+	cour := sniffSamples["COUR"]
+	comp := strings.Split(sniffSamples["COMP"], "\n")
+	compSpec := GetCOMPSpec()
+
+	paths := writeReturn(t, map[string]string{
+		"STUD9170.txt": stud + "\n" + young + "\n",
+		"COUR9170.txt": cour + "\n" + withField(CourseEnrolmentSpec, cour, "ID", "917000048") + "\n",
+		"COMP9170.txt": strings.Join([]string{
+			comp[0],
+			withField(compSpec, comp[0], "CRS_END", "07062024"), // End differs from COUR
+			withField(compSpec, withField(compSpec, comp[0], "CRS_SRT", "29092023"), // No enrolment starts then
+				"CRS_END", "07062024"),
+			comp[1],                               // No COUR enrolment at all: not a date problem
+			"9170917000047 2102-530            0", // Too short to hold the required dates
+		}, "\n"),
+	})
+
+	result := ValidateReturn(paths, ProcessOptions{})
+	if result.Error != nil {
+		t.Fatalf("ValidateReturn failed: %v", result.Error)
+	}
+	if len(result.Files) != 3 || filepath.Base(result.Files[0]) != "STUD9170.txt" || result.RecordCount != 8 {
+		t.Errorf("Unexpected files %v and record count %d", result.Files, result.RecordCount)
+	}
+
+	ages := findingsByRule(result.Findings, "student-age")
+	if len(ages) != 1 || ages[0].File != "STUD9170.txt" || ages[0].Line != 2 || ages[0].Severity != SeverityWarning ||
+		!strings.Contains(ages[0].Message, "would be 3") {
+		t.Errorf("Unexpected age findings: %v", ages)
+	}
+
+	dates := findingsByRule(result.Findings, "completion-dates")
+	if len(dates) != 1 || dates[0].Line != 2 || dates[0].Field != "CRS_END" || !strings.Contains(dates[0].Message, "(06062024, COUR line 1)") {
+		t.Errorf("Unexpected end date findings: %v", dates)
+	}
+
+	// A COMP start date that matches no enrolment is reported as a missing enrolment, with the likely dates
+	enrolments := findingsByRule(result.Findings, "comp-enrolment")
+	if len(enrolments) != 2 || enrolments[0].Line != 3 || enrolments[0].Field != "CRS_SRT" ||
+		!strings.Contains(enrolments[0].Message, "COUR start dates: 28092023") {
+		t.Errorf("Unexpected enrolment findings: %v", enrolments)
+	}
+
+	if parse := findingsByRule(result.Findings, "parse"); len(parse) != 1 || parse[0].Line != 5 {
+		t.Errorf("Expected the bad COMP line to be reported, got %v", parse)
+	}
+}
+
+func TestValidateReturn_OneFilePerType(t *testing.T) {
+	paths := writeReturn(t, map[string]string{
+		"COUR9170.txt":         sniffSamples["COUR"],
+		"COUR9170_rebuilt.txt": sniffSamples["COUR"],
+	})
+
+	result := ValidateReturn(paths, ProcessOptions{})
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "are both COUR files") {
+		t.Errorf("Expected duplicate file type error, got: %v", result.Error)
+	}
+}
+
+func TestValidateReturn_ReferentialIntegrity(t *testing.T) {
+	studSpec := GetSTUDSpec()
+	stud := withField(studSpec, strings.Split(sampleSTUDData, "\n")[0], "INSTIT", "9170")
+	stud = withField(studSpec, stud, "ID", "917000047")
+	stud = withField(studSpec, stud, "NSN", "120331711")
+
+	cour := sniffSamples["COUR"]
+	comp := strings.Split(sniffSamples["COMP"], "\n")[0]
+	qual := strings.Split(sniffSamples["QUAL"], "\n")[0]
+	qualSpec := GetQUALSpec()
+	qual = withField(qualSpec, withField(qualSpec, qual, "ID", "917000047"), "NSN", "120331711")
+
+	paths := writeReturn(t, map[string]string{
+		"STUD9170.txt": stud,
+		"CREG9170.txt": sampleCREGData,
+		"COUR9170.txt": strings.Join([]string{
+			cour,
+			withField(CourseEnrolmentSpec, cour, "ID", "917000099"),    // Not in STUD
+			withField(CourseEnrolmentSpec, cour, "QUAL", "NZ2101"),     // Registered for NZ2102 only
+			withField(CourseEnrolmentSpec, cour, "COURSE", "9999-999"), // Not registered at all
+		}, "\n"),
+		"COMP9170.txt": comp + "\n" + withField(GetCOMPSpec(), comp, "COURSE", "2102-999"), // Never enrolled
+		"QUAL9170.txt": strings.Join([]string{
+			withField(qualSpec, qual, "QUAL", "NZ2102"),
+			qual, // NZ2101, which 917000047 has a COUR enrolment in
+			withField(qualSpec, qual, "QUAL", "NZ2103"), // No enrolment in NZ2103
+		}, "\n"),
+	})
+
+	result := ValidateReturn(paths, ProcessOptions{})
+	if result.Error != nil {
+		t.Fatalf("ValidateReturn failed: %v", result.Error)
+	}
+
+	students := findingsByRule(result.Findings, "cour-student")
+	if len(students) != 1 || students[0].Line != 2 || students[0].Value != "917000099" {
+		t.Errorf("Unexpected student findings: %v", students)
+	}
+
+	courses := findingsByRule(result.Findings, "cour-course")
+	if len(courses) != 2 || courses[0].Line != 3 || !strings.Contains(courses[0].Message, "registered in CREG for NZ2102, not NZ2101") ||
+		courses[1].Line != 4 || !strings.Contains(courses[1].Message, "9999-999 is not in CREG") {
+		t.Errorf("Unexpected course findings: %v", courses)
+	}
+
+	if completions := findingsByRule(result.Findings, "comp-enrolment"); len(completions) != 1 || completions[0].Line != 2 ||
+		!strings.Contains(completions[0].Message, "no COUR enrolment in 2102-999") {
+		t.Errorf("Unexpected completion findings: %v", completions)
+	}
+
+	if quals := findingsByRule(result.Findings, "qual-enrolment"); len(quals) != 1 || quals[0].Line != 3 || quals[0].Value != "NZ2103" {
+		t.Errorf("Unexpected qualification findings: %v", quals)
+	}
+
+	if missing := findingsByRule(result.Findings, "missing-file"); len(missing) != 0 {
+		t.Errorf("Expected no missing files, got %v", missing)
+	}
+}
+
+func TestGroupFindings(t *testing.T) {
+	findings := []Finding{
+		{Rule: "student-age", Severity: SeverityWarning, Line: 1},
+		{Rule: "cour-student", Severity: SeverityError, Line: 1},
+		{Rule: "cour-student", Severity: SeverityError, Line: 2},
+		{Rule: "cour-student", Severity: SeverityError, Line: 3},
+	}
+
+	groups := GroupFindings(findings, 2)
+	if len(groups) != 2 || groups[0].Rule != "student-age" || groups[0].Severity != SeverityWarning {
+		t.Fatalf("Unexpected groups: %+v", groups)
+	}
+	if groups[1].Count != 3 || len(groups[1].Samples) != 2 || groups[1].Samples[1].Line != 2 ||
+		groups[1].Title != ruleTitles["cour-student"] {
+		t.Errorf("Unexpected cour-student group: %+v", groups[1])
+	}
+}
+
+func TestValidateReturn_CourseFactors(t *testing.T) {
+	cour := sniffSamples["COUR"]
+	paths := writeReturn(t, map[string]string{
+		"CREG9170.txt": sampleCREGData,
+		"COUR9170.txt": strings.Join([]string{
+			cour,
+			withField(CourseEnrolmentSpec, cour, "FACTOR", "0.1200"),
+			withField(CourseEnrolmentSpec, withField(CourseEnrolmentSpec, cour, "ID", "917000048"), "FACTOR", "0.1200"),
+		}, "\n"),
+	})
+
+	result := ValidateReturn(paths, ProcessOptions{})
+	if result.Error != nil {
+		t.Fatalf("ValidateReturn failed: %v", result.Error)
+	}
+
+	factors := findingsByRule(result.Findings, "efts-factor")
+	if len(factors) != 1 || factors[0].Line != 2 || factors[0].Value != "0.1200" ||
+		!strings.Contains(factors[0].Message, "CREG FACTOR 0.1167 of course 2102-530 (CREG line 1; difference +0.0033) on 2 enrolments") {
+		t.Errorf("Unexpected factor findings: %v", factors)
+	}
+}
+
+func TestValidateReturn_ProviderCodes(t *testing.T) {
+	studSpec := GetSTUDSpec()
+	stud := withField(studSpec, strings.Split(sampleSTUDData, "\n")[0], "INSTIT", "9170")
+	cour := sniffSamples["COUR"]
+
+	// The names and INSTIT values agree
+	result := ValidateReturn(writeReturn(t, map[string]string{"STUD9170.txt": stud, "COUR9170.txt": cour}), ProcessOptions{})
+	for _, rule := range []string{"provider-file", "provider-mixed", "provider-return"} {
+		if findings := findingsByRule(result.Findings, rule); len(findings) != 0 {
+			t.Errorf("Expected no %s findings, got %v", rule, findings)
+		}
+	}
+	if result.Blocked() {
+		t.Errorf("Expected the return not to be blocked: %v", result.Findings)
+	}
+
+	// A year in the name is not taken for the provider code
+	result = ValidateReturn(writeReturn(t, map[string]string{"STUD9170_2024.txt": stud, "COUR2024_9170.txt": cour, "CREG_2024.txt": sampleCREGData}), ProcessOptions{})
+	if result.Blocked() {
+		t.Errorf("Expected the return not to be blocked: %v", result.Findings)
+	}
+	for name, expected := range map[string]string{"COUR2024_9170.txt": "9170", "COUR-9170.dat": "9170", "COUR9170_2024-25.txt": "9170", "COUR2024.txt": "", "COUR917.txt": "", "COUR9170_1234.txt": ""} {
+		if code, _ := providerOf(&ReturnFile{Path: name, FileType: "COUR", Spec: CourseEnrolmentSpec}); code != expected {
+			t.Errorf("%s: expected provider %q, got %q", name, expected, code)
+		}
+	}
+
+	result = ValidateReturn(writeReturn(t, map[string]string{
+		"STUD9170.txt":    stud,
+		"COUR9170.txt":    cour + "\n" + withField(CourseEnrolmentSpec, withField(CourseEnrolmentSpec, cour, "ID", "917000048"), "INSTIT", "1234"),
+		"CREG1234.txt":    sampleCREGData,       // INSTIT 9170 throughout
+		"completions.txt": sniffSamples["COMP"], // No provider in the name, so its INSTIT counts
+	}), ProcessOptions{})
+	if result.Error != nil {
+		t.Fatalf("ValidateReturn failed: %v", result.Error)
+	}
+	if !result.Blocked() {
+		t.Error("Expected the return to be blocked")
+	}
+
+	named := findingsByRule(result.Findings, "provider-file")
+	if len(named) != 2 || named[0].File != "COUR9170.txt" || named[0].Line != 2 || named[0].Severity != SeverityBlocking ||
+		named[0].Message != "INSTIT 1234 on 1 record(s) does not match provider code 9170 in the file name" {
+		t.Fatalf("Unexpected file name findings: %v", named)
+	}
+	if named[1].File != "CREG1234.txt" || named[1].Line != 1 || named[1].Value != "9170" ||
+		!strings.Contains(named[1].Message, "does not match provider code 1234 in the file name") {
+		t.Errorf("Unexpected CREG finding: %+v", named[1])
+	}
+
+	mixed := findingsByRule(result.Findings, "provider-mixed")
+	if len(mixed) != 1 || mixed[0].File != "COUR9170.txt" ||
+		!strings.HasSuffix(mixed[0].Message, "2 providers: 9170 (1 record(s) from line 1), 1234 (1 record(s) from line 2)") {
+		t.Errorf("Unexpected mixed provider findings: %v", mixed)
+	}
+
+	spread := findingsByRule(result.Findings, "provider-return")
+	expected := "the files are for 2 providers: 9170 (STUD9170.txt, COUR9170.txt, completions.txt); 1234 (CREG1234.txt)"
+	if len(spread) != 1 || spread[0].Message != expected {
+		t.Errorf("Expected %q, got %v", expected, spread)
+	}
+}
@@ -0,0 +1,366 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ruleFileExt is the extension of rule files in a rule directory
+const ruleFileExt = ".json"
+
+// Rule is a user-defined validation rule, read from a JSON rule file. For every
+// record of FileType for which When holds, Check must hold; otherwise the record
+// gets a finding with the rule's Name, Severity and Message.
+type Rule struct {
+	Name        string     `json:"name"`                  // Rule name in findings, e.g. "funding-01-residency"
+	Description string     `json:"description,omitempty"` // What the rule checks, for reports grouped by rule
+	FileType    string     `json:"file_type"`             // File type whose records are checked
+	Join        []RuleJoin `json:"join,omitempty"`        // Other file types whose fields the expressions use
+	When        string     `json:"when,omitempty"`        // Condition for the rule to apply (always, if empty)
+	Check       string     `json:"check"`                 // Condition every applicable record must meet
+	Severity    Severity   `json:"severity,omitempty"`    // Defaults to error
+	Field       string     `json:"field,omitempty"`       // Field the finding is about
+	// Message describes a failing record; {FIELD} and {TYPE.FIELD} are replaced by values
+	Message string `json:"message"`
+
+	when, check exprNode
+	message     []messagePart
+}
+
+// RuleJoin joins the record being checked to the first record of another file type
+// with the same values in the On fields. Without a match the joined fields are blank.
+type RuleJoin struct {
+	FileType string   `json:"file_type"`
+	On       []string `json:"on,omitempty"` // Fields present in both file types; defaults to the joined type's Key
+}
+
+// messagePart is literal text of a rule message, or a field whose value is inserted
+type messagePart struct {
+	text  string
+	field *fieldRef
+}
+
+// ruleMessageField matches a field placeholder in a rule message
+var ruleMessageField = regexp.MustCompile(`\{([A-Za-z0-9_.]+)\}`)
+
+// validationRules holds the user-defined rules in use
+var validationRules []Rule
+
+// Rules returns the user-defined rules in use
+func Rules() []Rule {
+	return validationRules
+}
+
+// UseRules compiles rules and, if they are all valid, replaces the rules in use
+func UseRules(rules []Rule) error {
+	compiled := make([]Rule, len(rules))
+	names := make(map[string]bool)
+	for i, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return err
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("rule %s is defined more than once", rule.Name)
+		}
+		names[rule.Name] = true
+		compiled[i] = rule
+	}
+	validationRules = compiled
+	return nil
+}
+
+// compile checks the rule and compiles its expressions against the registered specs
+func (r *Rule) compile() error {
+	r.FileType = strings.ToUpper(r.FileType)
+	if r.Name == "" {
+		return errors.New("rule has no name")
+	}
+	if _, builtIn := ruleTitles[r.Name]; builtIn {
+		return fmt.Errorf("rule %s: the name is taken by a built-in check", r.Name)
+	}
+	if r.Severity == "" {
+		r.Severity = SeverityError
+	}
+	if r.Severity != SeverityError && r.Severity != SeverityWarning {
+		return fmt.Errorf("rule %s: severity %q is not %s or %s", r.Name, r.Severity, SeverityError, SeverityWarning)
+	}
+
+	scope, err := r.scope()
+	if err != nil {
+		return fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+
+	if r.Check == "" {
+		return fmt.Errorf("rule %s: check is empty", r.Name)
+	}
+	if r.check, err = compileCondition(r.Check, scope); err != nil {
+		return fmt.Errorf("rule %s: check: %w", r.Name, err)
+	}
+	if r.When != "" {
+		if r.when, err = compileCondition(r.When, scope); err != nil {
+			return fmt.Errorf("rule %s: when: %w", r.Name, err)
+		}
+	}
+
+	if r.Field != "" {
+		ref, err := scope.resolve(r.Field)
+		if err != nil || ref.fileType != r.FileType {
+			return fmt.Errorf("rule %s: field %s is not a field of %s", r.Name, r.Field, r.FileType)
+		}
+		r.Field = ref.name
+	}
+
+	if r.Message == "" {
+		return fmt.Errorf("rule %s: message is empty", r.Name)
+	}
+	r.message = nil
+	last := 0
+	for _, match := range ruleMessageField.FindAllStringSubmatchIndex(r.Message, -1) {
+		ref, err := scope.resolve(r.Message[match[2]:match[3]])
+		if err != nil {
+			return fmt.Errorf("rule %s: message: %w", r.Name, err)
+		}
+		r.message = append(r.message, messagePart{text: r.Message[last:match[0]]}, messagePart{field: &ref})
+		last = match[1]
+	}
+	r.message = append(r.message, messagePart{text: r.Message[last:]})
+	return nil
+}
+
+// scope returns the fields the rule's expressions may use, checking its joins
+func (r *Rule) scope() (exprScope, error) {
+	reg, ok := LookupFileType(r.FileType)
+	if !ok {
+		return exprScope{}, fmt.Errorf("unknown file type %q", r.FileType)
+	}
+	scope := exprScope{
+		fileType: r.FileType,
+		fields:   map[string][]string{r.FileType: columnNames(reg.Spec)},
+	}
+
+	for i := range r.Join {
+		join := &r.Join[i]
+		join.FileType = strings.ToUpper(join.FileType)
+		joined, ok := LookupFileType(join.FileType)
+		if !ok {
+			return exprScope{}, fmt.Errorf("join: unknown file type %q", join.FileType)
+		}
+		if _, exists := scope.fields[join.FileType]; exists {
+			return exprScope{}, fmt.Errorf("join: %s is joined more than once", join.FileType)
+		}
+		scope.fields[join.FileType] = columnNames(joined.Spec)
+
+		// Copied, as the names are upper-cased in place below
+		join.On = append([]string(nil), join.On...)
+		if len(join.On) == 0 {
+			join.On = append([]string(nil), joined.Spec.Key...)
+		}
+		if len(join.On) == 0 {
+			return exprScope{}, fmt.Errorf("join: %s has no key, so the join needs \"on\" fields", join.FileType)
+		}
+		for j, name := range join.On {
+			join.On[j] = strings.ToUpper(name)
+			if _, ok := reg.Spec.Field(join.On[j]); !ok {
+				return exprScope{}, fmt.Errorf("join: %s has no field %s to join %s on", r.FileType, join.On[j], join.FileType)
+			}
+			if _, ok := joined.Spec.Field(join.On[j]); !ok {
+				return exprScope{}, fmt.Errorf("join: %s has no field %s to join on", join.FileType, join.On[j])
+			}
+		}
+	}
+	return scope, nil
+}
+
+// columnNames returns the record keys of a spec
+func columnNames(spec FileSpec) []string {
+	var names []string
+	for _, column := range spec.Columns() {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+// ruleRecords are the record a rule checks and the records joined to it, by file type
+type ruleRecords map[string]Record
+
+// value looks up a field for the rule's expressions; a missing join reads as blank
+func (r ruleRecords) value(fileType, name string) string {
+	return r[fileType].Get(name)
+}
+
+// fails reports whether the record breaks the rule
+func (r *Rule) fails(records ruleRecords) bool {
+	if r.when != nil && !r.when.eval(records.value).(bool) {
+		return false
+	}
+	return !r.check.eval(records.value).(bool)
+}
+
+// messageFor returns the rule's message with the record's values filled in
+func (r *Rule) messageFor(records ruleRecords) string {
+	var message strings.Builder
+	for _, part := range r.message {
+		if part.field != nil {
+			message.WriteString(records.value(part.field.fileType, part.field.name))
+		} else {
+			message.WriteString(part.text)
+		}
+	}
+	return message.String()
+}
+
+// checkRules checks the record against the user-defined rules of its file type that
+// need no other file; rules with joins are checked over a whole return by checkJoinedRules
+func (v *Validator) checkRules(record Record, src Source) {
+	for i := range validationRules {
+		rule := &validationRules[i]
+		if rule.FileType != v.spec.FileType || len(rule.Join) > 0 {
+			continue
+		}
+		records := ruleRecords{rule.FileType: record}
+		if rule.fails(records) {
+			v.report(rule.Name, rule.Severity, src, rule.Field, record.Get(rule.Field), rule.messageFor(records))
+		}
+	}
+}
+
+// checkJoinedRules checks the user-defined rules that join file types. A rule whose
+// file type is missing from the return is skipped; a missing joined file joins nothing.
+func checkJoinedRules(r *Return) []Finding {
+	var findings []Finding
+	indexes := make(map[string]map[string]int) // "TYPE/ON" -> join key -> first record
+
+	for i := range validationRules {
+		rule := &validationRules[i]
+		file := r.File(rule.FileType)
+		if file == nil || len(rule.Join) == 0 {
+			continue
+		}
+
+		for j, record := range file.Records {
+			records := ruleRecords{rule.FileType: record}
+			for _, join := range rule.Join {
+				joined := r.File(join.FileType)
+				if joined == nil {
+					continue
+				}
+				index := joinIndex(indexes, joined, join.On)
+				if k, ok := index[recordKey(record, join.On)]; ok {
+					records[join.FileType] = joined.Records[k]
+				}
+			}
+
+			if rule.fails(records) {
+				finding := file.finding(j, rule.Name, rule.Severity, rule.Field, rule.messageFor(records))
+				findings = append(findings, finding)
+			}
+		}
+	}
+	return findings
+}
+
+// joinIndex returns the first record of the file for each value of the join fields,
+// building it once per file and set of fields
+func joinIndex(indexes map[string]map[string]int, file *ReturnFile, on []string) map[string]int {
+	name := file.FileType + "/" + strings.Join(on, "+")
+	if index, ok := indexes[name]; ok {
+		return index
+	}
+
+	index := make(map[string]int)
+	for i, record := range file.Records {
+		key := recordKey(record, on)
+		if _, exists := index[key]; !exists {
+			index[key] = i
+		}
+	}
+	indexes[name] = index
+	return index
+}
+
+// recordKey joins the values of the named fields of a record
+func recordKey(record Record, names []string) string {
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = record.Get(name)
+	}
+	return joinKey(values...)
+}
+
+// DefaultRuleDir returns the per-user directory searched for rule files
+func DefaultRuleDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config directory: %w", err)
+	}
+	return filepath.Join(configDir, "oh-no-sdr", "rules"), nil
+}
+
+// ReadRuleFile reads a JSON array of rules and compiles them
+func ReadRuleFile(path string) ([]Rule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rule file: %w", err)
+	}
+	defer file.Close()
+
+	var rules []Rule
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields() // A misspelt key would otherwise be silently ignored
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("invalid rule file %s: %w", filepath.Base(path), err)
+	}
+
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("invalid rule file %s: %w", filepath.Base(path), err)
+		}
+	}
+	return rules, nil
+}
+
+// LoadRuleDir reads every JSON rule file in a directory and puts their rules in use,
+// so institution-specific checks need no code changes.
+// Nothing is installed unless every rule file is valid.
+func LoadRuleDir(dir string) ([]Rule, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rule directory: %w", err)
+	}
+
+	var rules []Rule
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ruleFileExt) {
+			continue
+		}
+
+		fileRules, err := ReadRuleFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	if err := UseRules(rules); err != nil {
+		return nil, fmt.Errorf("invalid rules in %s: %w", dir, err)
+	}
+	return rules, nil
+}
+
+// ruleTitle describes a rule for reports: a built-in check or a user-defined rule
+func ruleTitle(name string) string {
+	if title, ok := ruleTitles[name]; ok {
+		return title
+	}
+	for _, rule := range validationRules {
+		if rule.Name == name {
+			return rule.Description
+		}
+	}
+	return ""
+}
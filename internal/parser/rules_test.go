@@ -0,0 +1,207 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTestRules installs rules for the duration of a test
+func useTestRules(t *testing.T, rules ...Rule) {
+	t.Helper()
+	previous := validationRules
+	t.Cleanup(func() { validationRules = previous })
+	if err := UseRules(rules); err != nil {
+		t.Fatalf("UseRules failed: %v", err)
+	}
+}
+
+func TestCompileCondition(t *testing.T) {
+	scope := exprScope{fileType: "COUR", fields: map[string][]string{
+		"COUR": columnNames(CourseEnrolmentSpec),
+		"STUD": columnNames(GetSTUDSpec()),
+	}}
+	record, err := NewCourseEnrolmentParser().Parse(sniffSamples["COUR"])
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	records := ruleRecords{"COUR": record[0]} // No STUD record joined
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{`FUNDING == "37" && ATTEND == "2"`, true},
+		{`FUNDING == "01" or not (ATTEND = '1')`, true},
+		{`FUNDING == 37`, true},
+		{`ASSIST == "0"`, false},
+		{`ASSIST == 0`, true},
+		{`FACTOR > 0.1 and FACTOR <= 0.1167`, true},
+		{`CATEGORY in ["P1", "P2"] && RESIDENCY not in ["Y"]`, true},
+		{`date(CRS_SRT) < date("01012024") && date(CRS_END) >= "20240101"`, true},
+		{`starts_with(QUAL, "NZ") && len(COURSE) == 8 && !empty(NSN)`, true},
+		{`empty(STUD.CITIZEN)`, true},
+		{`efts_mth_01 > efts_mth_06`, true},
+	}
+	for _, test := range tests {
+		node, err := compileCondition(test.expr, scope)
+		if err != nil {
+			t.Errorf("%s: %v", test.expr, err)
+			continue
+		}
+		if actual := node.eval(records.value).(bool); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.expr, test.expected, actual)
+		}
+	}
+
+	errors := []struct {
+		expr     string
+		expected string
+	}{
+		{`FUNDNG == "01"`, "position 1: COUR has no field FUNDNG"},
+		{`CREG.FACTOR > 0`, "position 1: CREG is not the rule's file type or a joined one"},
+		{`FUNDING == "01`, "position 12: text is not closed"},
+		{`FUNDING`, "expression is text, not a condition"},
+		{`FUNDING == "01" &&`, "position 19: expression is incomplete (at the end)"},
+		{`FUNDING && ATTEND == "1"`, "position 9: && needs a condition on each side"},
+		{`(ATTEND == "1"`, "expected ) (at the end)"},
+		{`ATTEND in "1"`, "position 11: expected [ to start a list"},
+		{`lower(ATTEND) == "1"`, "position 1: unknown function lower"},
+		{`starts_with(ATTEND) `, "starts_with takes 2 argument(s), not 1"},
+		{`ATTEND == "1" ATTEND`, `position 15: unexpected "ATTEND"`},
+		{`ATTEND # 1`, "position 8: unexpected character '#'"},
+	}
+	for _, test := range errors {
+		_, err := compileCondition(test.expr, scope)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: expected error containing %q, got %v", test.expr, test.expected, err)
+		}
+	}
+}
+
+func TestValidator_UserRules(t *testing.T) {
+	useTestRules(t, Rule{
+		Name:     "funding-37-attend",
+		FileType: "cour",
+		When:     `FUNDING == "37"`,
+		Check:    `ATTEND == "1"`,
+		Severity: SeverityWarning,
+		Field:    "attend",
+		Message:  "funding {FUNDING} is for intramural students; {ID} has ATTEND {ATTEND}",
+	})
+
+	line := sniffSamples["COUR"] // FUNDING 37, ATTEND 2
+	content := line + "\n" + withField(CourseEnrolmentSpec, withField(CourseEnrolmentSpec, line, "ATTEND", "1"), "ID", "917000048")
+
+	validator := NewValidator(CourseEnrolmentSpec)
+	if err := NewCourseEnrolmentParser().ParseReader(strings.NewReader(content), validator.Check); err != nil {
+		t.Fatalf("ParseReader failed: %v", err)
+	}
+
+	findings := findingsByRule(validator.Findings(), "funding-37-attend")
+	if len(findings) != 1 {
+		t.Fatalf("Expected 1 finding, got %v", validator.Findings())
+	}
+	expected := "line 1: ATTEND: funding 37 is for intramural students; 917000047 has ATTEND 2"
+	if findings[0].String() != expected || findings[0].Severity != SeverityWarning || findings[0].Value != "2" {
+		t.Errorf("Expected %q, got %+v", expected, findings[0])
+	}
+}
+
+func TestValidateReturn_JoinedRules(t *testing.T) {
+	useTestRules(t, Rule{
+		Name:        "international-assist-citizen",
+		Description: "International fee assessment for New Zealand citizens",
+		FileType:    "COUR",
+		Join:        []RuleJoin{{FileType: "STUD"}},
+		When:        `ASSIST not in ["", "00"]`,
+		Check:       `STUD.CITIZEN != "NZL"`,
+		Field:       "ASSIST",
+		Message:     "ASSIST {ASSIST} is for international students, but {ID} is a {STUD.CITIZEN} citizen",
+	})
+	if reg, _ := LookupFileType("STUD"); &validationRules[0].Join[0].On[0] == &reg.Spec.Key[0] {
+		t.Error("Expected the join to copy the STUD key rather than share it")
+	}
+
+	studSpec := GetSTUDSpec()
+	stud := withField(studSpec, strings.Split(sampleSTUDData, "\n")[0], "INSTIT", "9170") // CITIZEN NZL
+	stud = withField(studSpec, stud, "ID", "917000047")
+	overseas := withField(studSpec, withField(studSpec, stud, "ID", "917000048"), "CITIZEN", "AUS")
+
+	cour := withField(CourseEnrolmentSpec, sniffSamples["COUR"], "ASSIST", "02")
+	paths := writeReturn(t, map[string]string{
+		"STUD9170.txt": stud + "\n" + overseas,
+		"COUR9170.txt": strings.Join([]string{
+			cour, // NZL citizen assessed as international
+			withField(CourseEnrolmentSpec, cour, "ID", "917000048"),
+			withField(CourseEnrolmentSpec, cour, "ID", "917000049"), // Not in STUD: CITIZEN reads blank
+			withField(CourseEnrolmentSpec, sniffSamples["COUR"], "ID", "917000050"),
+		}, "\n"),
+	})
+
+	result := ValidateReturn(paths, ProcessOptions{})
+	if result.Error != nil {
+		t.Fatalf("ValidateReturn failed: %v", result.Error)
+	}
+
+	findings := findingsByRule(result.Findings, "international-assist-citizen")
+	if len(findings) != 1 || findings[0].File != "COUR9170.txt" || findings[0].Line != 1 ||
+		findings[0].Message != "ASSIST 02 is for international students, but 917000047 is a NZL citizen" {
+		t.Errorf("Unexpected findings: %v", findings)
+	}
+
+	groups := GroupFindings(findings, 1)
+	if len(groups) != 1 || groups[0].Title != "International fee assessment for New Zealand citizens" {
+		t.Errorf("Unexpected groups: %+v", groups)
+	}
+}
+
+func TestLoadRuleDir(t *testing.T) {
+	previous := validationRules
+	t.Cleanup(func() { validationRules = previous })
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	write("cour.json", `[{"name": "cour-factor", "file_type": "COUR", "check": "FACTOR > 0", "message": "no FACTOR"}]`)
+	write("notes.txt", "not a rule file")
+
+	rules, err := LoadRuleDir(dir)
+	if err != nil {
+		t.Fatalf("LoadRuleDir failed: %v", err)
+	}
+	if len(rules) != 1 || len(Rules()) != 1 || Rules()[0].Severity != SeverityError {
+		t.Errorf("Unexpected rules: %+v", Rules())
+	}
+
+	// A bad file installs nothing, and the error names the file and rule
+	invalid := []struct {
+		content  string
+		expected string
+	}{
+		{`[{"name": "stud-join", "file_type": "STUD", "join": [{"file_type": "QUAL"}], "check": "QUAL.QUAL != \"\"", "message": "x"}]`,
+			"invalid rule file stud.json: rule stud-join: join: STUD has no field QUAL to join QUAL on"},
+		{`[{"name": "code-set", "file_type": "STUD", "check": "1 == 1", "message": "x"}]`,
+			"rule code-set: the name is taken by a built-in check"},
+		{`[{"name": "bad-severity", "file_type": "STUD", "check": "1 == 1", "severity": "fatal", "message": "x"}]`,
+			`severity "fatal" is not error or warning`},
+		{`[{"name": "bad-message", "file_type": "STUD", "check": "1 == 1", "message": "{NOPE}"}]`,
+			"rule bad-message: message: STUD has no field NOPE"},
+		{`[{"name": "cour-factor", "file_type": "STUD", "check": "1 == 1", "message": "x"}]`,
+			"rule cour-factor is defined more than once"},
+		{`[{"name": "typo", "file_typ": "STUD"}]`, `unknown field "file_typ"`},
+	}
+	for _, test := range invalid {
+		write("stud.json", test.content)
+		if _, err := LoadRuleDir(dir); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("Expected error containing %q, got %v", test.expected, err)
+		}
+		if len(Rules()) != 1 || Rules()[0].Name != "cour-factor" {
+			t.Errorf("Rules changed by an invalid file: %+v", Rules())
+		}
+	}
+}
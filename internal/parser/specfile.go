@@ -0,0 +1,221 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// specFileExt is the extension of spec files in a spec directory
+const specFileExt = ".json"
+
+// DefaultSpecDir returns the per-user directory searched for spec overrides
+func DefaultSpecDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config directory: %w", err)
+	}
+	return filepath.Join(configDir, "oh-no-sdr", "specs"), nil
+}
+
+// ReadSpecFile reads a FileSpec from a JSON file and validates it
+func ReadSpecFile(path string) (FileSpec, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return FileSpec{}, fmt.Errorf("failed to read spec file: %w", err)
+	}
+	defer file.Close()
+
+	var spec FileSpec
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields() // A misspelt key would otherwise be silently ignored
+	if err := decoder.Decode(&spec); err != nil {
+		return FileSpec{}, fmt.Errorf("invalid spec file %s: %w", filepath.Base(path), err)
+	}
+	spec.FileType = strings.ToUpper(spec.FileType)
+
+	if err := spec.Validate(); err != nil {
+		return FileSpec{}, fmt.Errorf("invalid spec file %s: %w", filepath.Base(path), err)
+	}
+	return spec, nil
+}
+
+// WriteSpecFile writes a FileSpec to a JSON file
+func WriteSpecFile(path string, spec FileSpec) error {
+	data, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s spec: %w", spec.FileType, err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write spec file: %w", err)
+	}
+	return nil
+}
+
+// LoadSpecDir reads every JSON spec in a directory and installs it with UseSpec,
+// so a corrected layout takes effect without rebuilding the binary.
+// Nothing is installed unless every spec file is valid.
+func LoadSpecDir(dir string) ([]FileSpec, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec directory: %w", err)
+	}
+
+	var specs []FileSpec
+	seen := make(map[string]string) // "TYPE/year" -> file that defined it
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), specFileExt) {
+			continue
+		}
+
+		spec, err := ReadSpecFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		key := fmt.Sprintf("%s/%d", spec.FileType, spec.Year)
+		if other, exists := seen[key]; exists {
+			return nil, fmt.Errorf("spec files %s and %s both define the %s layout for year %d",
+				other, entry.Name(), spec.FileType, spec.Year)
+		}
+		seen[key] = entry.Name()
+		specs = append(specs, spec)
+	}
+
+	for _, spec := range specs {
+		UseSpec(spec)
+	}
+	return specs, nil
+}
+
+// ExportSpecs writes every registered layout to a directory as JSON, as a starting
+// point for overrides. It returns the paths of the files written.
+func ExportSpecs(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spec directory: %w", err)
+	}
+
+	var paths []string
+	for _, reg := range registry {
+		for i, spec := range reg.Specs() {
+			name := strings.ToLower(spec.FileType)
+			if i > 0 {
+				// Earlier layouts are named after the year they took effect
+				name = fmt.Sprintf("%s_%d", name, spec.Year)
+			}
+
+			path := filepath.Join(dir, name+specFileExt)
+			if err := WriteSpecFile(path, spec); err != nil {
+				return paths, err
+			}
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// Validate checks that the spec describes a usable layout: named fields that cover
+// every position of the line exactly once, with lengths summing to LineLength
+func (s FileSpec) Validate() error {
+	var problems []error
+
+	if s.FileType == "" {
+		problems = append(problems, errors.New("file type is empty"))
+	}
+	if s.LineLength <= 0 {
+		problems = append(problems, fmt.Errorf("line length %d must be positive", s.LineLength))
+	}
+	if len(s.Fields) == 0 {
+		problems = append(problems, errors.New("no fields defined"))
+	}
+
+	problems = append(problems, validateFieldRanges(s.Fields, s.LineLength)...)
+
+	// Record keys must be unique, including sub-fields and totals
+	names := make(map[string]bool)
+	for _, column := range s.Columns() {
+		if column.Name == "" {
+			continue // Reported by validateFieldRanges
+		}
+		if names[column.Name] {
+			problems = append(problems, fmt.Errorf("field name %s is used more than once", column.Name))
+		}
+		names[column.Name] = true
+	}
+
+	for _, field := range s.Fields {
+		for _, f := range append([]FieldSpec{field}, field.SubFields...) {
+			if _, ok := LookupCodeSet(f.CodeSet); f.CodeSet != "" && !ok {
+				problems = append(problems, fmt.Errorf("field %s: unknown code set %s", f.Name, f.CodeSet))
+			}
+			if f.LabelTitle != "" && f.CodeSet == "" {
+				problems = append(problems, fmt.Errorf("field %s: a label column needs a code set", f.Name))
+			}
+		}
+
+		if len(field.SubFields) == 0 {
+			continue
+		}
+		for _, err := range validateFieldRanges(field.SubFields, field.Length) {
+			problems = append(problems, fmt.Errorf("field %s: %w", field.Name, err))
+		}
+	}
+
+	for _, name := range s.Key {
+		if !names[name] {
+			problems = append(problems, fmt.Errorf("key field %s is not a field of the spec", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s spec: %w", s.FileType, errors.Join(problems...))
+	}
+	return nil
+}
+
+// validateFieldRanges checks that fields lie within positions 1 to length without
+// overlapping. Positions not covered by any field are padding.
+func validateFieldRanges(fields []FieldSpec, length int) []error {
+	var problems []error
+
+	sorted := make([]FieldSpec, 0, len(fields))
+	for i, field := range fields {
+		if field.Name == "" {
+			problems = append(problems, fmt.Errorf("field %d has no name", i+1))
+		}
+		if field.Start < 1 || field.Length < 1 {
+			problems = append(problems, fmt.Errorf("field %s: start %d and length %d must be positive",
+				field.Name, field.Start, field.Length))
+			continue
+		}
+		sorted = append(sorted, field)
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+
+	next := 1 // First position not yet covered
+	var previous FieldSpec
+	for _, field := range sorted {
+		end := field.Start + field.Length - 1
+		if field.Start < next {
+			problems = append(problems, fmt.Errorf("fields %s (%d-%d) and %s (%d-%d) overlap",
+				previous.Name, previous.Start, previous.Start+previous.Length-1, field.Name, field.Start, end))
+		}
+		if end+1 > next {
+			next = end + 1
+			previous = field
+		}
+	}
+
+	if next-1 > length {
+		problems = append(problems, fmt.Errorf("fields run to position %d, past the line length of %d", next-1, length))
+	}
+
+	return problems
+}
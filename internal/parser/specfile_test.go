@@ -0,0 +1,200 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSpec_Validate_BuiltInSpecs(t *testing.T) {
+	for _, reg := range RegisteredFileTypes() {
+		for _, spec := range reg.Specs() {
+			if err := spec.Validate(); err != nil {
+				t.Errorf("Built-in %s spec (year %d) is invalid: %v", spec.FileType, spec.Year, err)
+			}
+		}
+	}
+}
+
+func TestFileSpec_Validate_Ranges(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   []FieldSpec
+		key      []string
+		expected string
+	}{
+		{
+			name: "overlap",
+			fields: []FieldSpec{
+				{Name: "CODE", Start: 1, Length: 5},
+				{Name: "NAME", Start: 5, Length: 5},
+				{Name: "FLAG", Start: 10, Length: 1},
+			},
+			expected: "fields CODE (1-5) and NAME (5-9) overlap",
+		},
+		{
+			name: "long",
+			fields: []FieldSpec{
+				{Name: "CODE", Start: 1, Length: 4},
+				{Name: "NAME", Start: 5, Length: 7},
+			},
+			expected: "fields run to position 11, past the line length of 10",
+		},
+		{
+			name: "duplicate",
+			fields: []FieldSpec{
+				{Name: "CODE", Start: 1, Length: 4},
+				{Name: "CODE", Start: 5, Length: 6},
+			},
+			expected: "field name CODE is used more than once",
+		},
+		{
+			name: "code set",
+			fields: []FieldSpec{
+				{Name: "CODE", Start: 1, Length: 4, CodeSet: "NOPE"},
+				{Name: "NAME", Start: 5, Length: 6},
+			},
+			expected: "field CODE: unknown code set NOPE",
+		},
+		{
+			name: "label",
+			fields: []FieldSpec{
+				{Name: "CODE", Start: 1, Length: 4, LabelTitle: "Code Label"},
+				{Name: "NAME", Start: 5, Length: 6},
+			},
+			expected: "field CODE: a label column needs a code set",
+		},
+		{
+			name: "key",
+			fields: []FieldSpec{
+				{Name: "CODE", Start: 1, Length: 4},
+				{Name: "NAME", Start: 5, Length: 6},
+			},
+			key:      []string{"CODE", "ID"},
+			expected: "key field ID is not a field of the spec",
+		},
+	}
+
+	for _, test := range tests {
+		spec := testSpec
+		spec.Fields = test.fields
+		spec.Key = test.key
+
+		err := spec.Validate()
+		if err == nil {
+			t.Errorf("%s: expected validation error", test.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: expected error containing '%s', got: %v", test.name, test.expected, err)
+		}
+	}
+}
+
+func TestFileSpec_Validate_Padding(t *testing.T) {
+	// Positions 8-9 belong to no field, so they are padding
+	spec := testSpec
+	spec.Fields = []FieldSpec{
+		{Name: "CODE", Start: 1, Length: 4},
+		{Name: "NAME", Start: 5, Length: 3},
+		{Name: "FLAG", Start: 10, Length: 1},
+	}
+	if err := spec.Validate(); err != nil {
+		t.Errorf("Expected padding positions to be valid, got: %v", err)
+	}
+
+	var records []map[string]string
+	err := NewFixedWidthParser(spec).ParseReader(strings.NewReader("ABCDxyz--Y\n"), func(record Record, src Source) error {
+		records = append(records, record.Map())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ParseReader failed: %v", err)
+	}
+	if len(records) != 1 || records[0]["NAME"] != "xyz" || records[0]["FLAG"] != "Y" {
+		t.Errorf("Expected NAME 'xyz' and FLAG 'Y', got %v", records)
+	}
+
+	// CREG's padding at 130-131 is not a field
+	for _, field := range GetCREGSpec().Fields {
+		if field.Start <= 131 && field.Start+field.Length-1 >= 130 {
+			t.Errorf("Expected no CREG field over the padding, got %s", field.Name)
+		}
+	}
+}
+
+func TestLoadSpecDir_OverridesBuiltInSpec(t *testing.T) {
+	// Loading changes the registry, so restore it afterwards
+	saved := RegisteredFileTypes()
+	defer func() { registry = saved }()
+
+	dir := t.TempDir()
+	if _, err := ExportSpecs(dir); err != nil {
+		t.Fatalf("ExportSpecs failed: %v", err)
+	}
+
+	// Patch the exported QUAL layout as a data manager would
+	path := filepath.Join(dir, "qual.json")
+	spec, err := ReadSpecFile(path)
+	if err != nil {
+		t.Fatalf("ReadSpecFile failed: %v", err)
+	}
+	spec.Fields[len(spec.Fields)-1].Title = "Reserved"
+	if err := WriteSpecFile(path, spec); err != nil {
+		t.Fatalf("WriteSpecFile failed: %v", err)
+	}
+
+	// Only JSON files are spec files; anything else in the directory is ignored
+	if err := os.WriteFile(filepath.Join(dir, "qual.yaml"), []byte("file_type: QUAL\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if _, err := LoadSpecDir(dir); err != nil {
+		t.Fatalf("LoadSpecDir failed: %v", err)
+	}
+
+	parser, err := GetParser("QUAL")
+	if err != nil {
+		t.Fatalf("GetParser failed: %v", err)
+	}
+	headers := parser.GetHeaders()
+	if headers[len(headers)-1] != "Reserved" {
+		t.Errorf("Expected patched header 'Reserved', got '%s'", headers[len(headers)-1])
+	}
+
+	// Exported layouts round-trip unchanged, sub-fields included
+	cour, _ := LookupFileType("COUR")
+	if len(cour.Spec.Columns()) != len(CourseEnrolmentSpec.Columns()) {
+		t.Errorf("Expected %d COUR columns after reload, got %d", len(CourseEnrolmentSpec.Columns()), len(cour.Spec.Columns()))
+	}
+}
+
+func TestLoadSpecDir_InvalidSpec(t *testing.T) {
+	saved := RegisteredFileTypes()
+	defer func() { registry = saved }()
+
+	dir := t.TempDir()
+	content := `{
+  "file_type": "QUAL",
+  "description": "Qualification Completion records",
+  "line_length": 10,
+  "fields": [
+    {"name": "INSTIT", "title": "Provider Code", "start": 1, "length": 4, "type": "code"},
+    {"name": "ID", "title": "Student Identification Code", "start": 4, "length": 7}
+  ]
+}`
+	if err := os.WriteFile(filepath.Join(dir, "qual.json"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write spec: %v", err)
+	}
+
+	_, err := LoadSpecDir(dir)
+	if err == nil || !strings.Contains(err.Error(), "overlap") {
+		t.Fatalf("Expected overlap error, got: %v", err)
+	}
+
+	// The built-in layout stays in place
+	if qual, _ := LookupFileType("QUAL"); qual.Spec.LineLength != GetQUALSpec().LineLength {
+		t.Errorf("Expected built-in QUAL spec to remain, got line length %d", qual.Spec.LineLength)
+	}
+}
@@ -1,6 +1,9 @@
 package parser
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -9,6 +12,11 @@ const sampleSTUDData = `1234567890123 M01011990  9999AAAAA01199920999199009NZL1
 1234567890456 F15061985  8888BBBBB02200520999198509AUS9 9N                      888888888    0    0222      56785678
 1234567890789 M22121992  7777CCCCC03199820999199209GBR0 1N                      777777777    0    0333      90129012`
 
+// sampleSTUDCodedData is sampleSTUDData with ethnicity codes from the bundled table (FAKE DATA)
+const sampleSTUDCodedData = `1234567890123 M01011990  9999AAAAA01199920999199009NZL1 1Y                      999999999    0    0111      12341234
+1234567890456 F15061985  8888BBBBB02200520999198509AUS9 9N                      888888888    0    0211      56785678
+1234567890789 M22121992  7777CCCCC03199820999199209GBR0 1N                      777777777    0    0311      90129012`
+
 func TestSTUDParser_Parse(t *testing.T) {
 	parser := NewSTUDParser()
 
@@ -37,7 +45,7 @@ func TestSTUDParser_Parse(t *testing.T) {
 	}
 
 	for fieldName, expectedValue := range expectedFields {
-		if actualValue, exists := firstRecord[fieldName]; !exists {
+		if actualValue, exists := firstRecord.Lookup(fieldName); !exists {
 			t.Errorf("Field %s missing from record", fieldName)
 		} else if actualValue != expectedValue {
 			t.Errorf("Field %s: expected '%s', got '%s'", fieldName, expectedValue, actualValue)
@@ -75,7 +83,7 @@ func TestSTUDParser_ParseSingleLine(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		if actual, exists := record[test.fieldName]; !exists {
+		if actual, exists := record.Lookup(test.fieldName); !exists {
 			t.Errorf("Field %s missing from record", test.fieldName)
 		} else if actual != test.expected {
 			t.Errorf("Field %s: expected '%s', got '%s'", test.fieldName, test.expected, actual)
@@ -87,9 +95,9 @@ func TestSTUDParser_GetHeaders(t *testing.T) {
 	parser := NewSTUDParser()
 	headers := parser.GetHeaders()
 
-	// Should have 25 headers (all fields in spec)
-	if len(headers) != 25 {
-		t.Errorf("Expected 25 headers, got %d", len(headers))
+	// 25 fields, plus three iwi codes and three ethnicity codes each with a label
+	if len(headers) != 37 {
+		t.Errorf("Expected 37 headers, got %d", len(headers))
 	}
 
 	// Test some specific headers
@@ -99,6 +107,10 @@ func TestSTUDParser_GetHeaders(t *testing.T) {
 		"Gender",
 		"Date of Birth",
 		"National Student Number",
+		"Iwi 3",
+		"Iwi 3 Label",
+		"Ethnicity 1",
+		"Ethnicity 1 Label",
 	}
 
 	for _, expected := range expectedHeaders {
@@ -169,3 +181,98 @@ func BenchmarkSTUDParser_Parse(b *testing.B) {
 		}
 	}
 }
+
+func TestSTUDParser_EthnicityAndIwi(t *testing.T) {
+	spec := GetSTUDSpec()
+	line := strings.Split(sampleSTUDData, "\n")[0]
+	line = withField(spec, line, "ETHNIC", "211311999")
+	line = withField(spec, line, "IWI", "01040106") // Two iwi codes, packed
+
+	records, err := NewSTUDParser().Parse(line)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"ETHNIC":         "211311999",
+		"ETHNIC_1":       "211",
+		"ETHNIC_1_LABEL": "Māori",
+		"ETHNIC_2_LABEL": "Samoan",
+		"ETHNIC_3":       "999",
+		"ETHNIC_3_LABEL": "Not Stated",
+		"IWI":            "01040106",
+		"IWI_1":          "0104",
+		"IWI_1_LABEL":    "",
+		"IWI_2":          "0106",
+		"IWI_3":          "",
+	}
+	for name, value := range expected {
+		if actual, ok := records[0].Lookup(name); !ok || actual != value {
+			t.Errorf("%s: expected %q, got %q", name, value, actual)
+		}
+	}
+
+	// Iwi codes are not checked without a table; ethnicity codes are
+	validator := NewValidator(spec)
+	validator.Check(records[0], Source{Line: 1})
+	bad, _ := NewSTUDParser().Parse(withField(spec, line, "ETHNIC", "222"))
+	validator.Check(bad[0], Source{Line: 2})
+	if findings := findingsByRule(validator.Findings(), "code-set"); len(findings) != 1 || findings[0].Field != "ETHNIC_1" || findings[0].Line != 2 {
+		t.Errorf("Expected one ETHNIC_1 finding, got %v", findings)
+	}
+}
+
+func TestSTUDParser_IwiCodeTable(t *testing.T) {
+	saved := codeSets
+	defer func() { codeSets = saved }()
+	UseCodeSet(CodeSet{Name: "IWI", Codes: []Code{{"0104", "Ngāti Kurī"}, {"0106", "Te Rarawa"}, {"0107", "Ngāti Kahu"}}})
+
+	// All twelve positions used: three iwi codes
+	spec := GetSTUDSpec()
+	line := withField(spec, strings.Split(sampleSTUDData, "\n")[0], "IWI", "010401060107")
+	records, err := NewSTUDParser().Parse(line)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	expected := map[string]string{
+		"IWI_1": "0104", "IWI_1_LABEL": "Ngāti Kurī",
+		"IWI_2": "0106", "IWI_2_LABEL": "Te Rarawa",
+		"IWI_3": "0107", "IWI_3_LABEL": "Ngāti Kahu",
+	}
+	for name, value := range expected {
+		if actual := records[0].Get(name); actual != value {
+			t.Errorf("%s: expected %q, got %q", name, value, actual)
+		}
+	}
+
+	// With a table, iwi codes are checked
+	validator := NewValidator(spec)
+	bad, _ := NewSTUDParser().Parse(withField(spec, line, "IWI", "01049999"))
+	validator.Check(bad[0], Source{Line: 1})
+	if findings := findingsByRule(validator.Findings(), "code-set"); len(findings) != 1 || findings[0].Field != "IWI_2" {
+		t.Errorf("Expected one IWI_2 finding, got %v", findings)
+	}
+}
+
+func TestFixedWidthWriter_IgnoresLabelColumns(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "STUD9170.txt")
+	if err := os.WriteFile(inputPath, []byte(sampleSTUDData+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+	if result := ProcessFile(inputPath, dir); !result.Success {
+		t.Fatalf("ProcessFile failed: %v", result.Error)
+	}
+
+	result := RebuildFile(filepath.Join(dir, "STUD9170_parsed.csv"), dir, ProcessOptions{})
+	if !result.Success {
+		t.Fatalf("RebuildFile failed: %v", result.Error)
+	}
+	rebuilt, err := os.ReadFile(result.OutputFile)
+	if err != nil {
+		t.Fatalf("Failed to read rebuilt file: %v", err)
+	}
+	if string(rebuilt) != sampleSTUDData+"\n" {
+		t.Errorf("Rebuilt file differs:\n%s", rebuilt)
+	}
+}
@@ -0,0 +1,166 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Column is a CSV output column derived from a FileSpec
+type Column struct {
+	Name  string // Record key
+	Title string // CSV header
+}
+
+// Columns returns the output columns of the spec: every field, each followed by
+// its sub-fields and, when the field has a TotalTitle, the computed total.
+// A field or sub-field with a LabelTitle is followed by its label column.
+func (s FileSpec) Columns() []Column {
+	columns := make([]Column, 0, len(s.Fields))
+	for _, field := range s.Fields {
+		columns = append(columns, field.valueColumns()...)
+		for _, sub := range field.SubFields {
+			columns = append(columns, sub.valueColumns()...)
+		}
+		if field.TotalTitle != "" {
+			columns = append(columns, Column{Name: field.TotalName(), Title: field.TotalTitle})
+		}
+	}
+	return columns
+}
+
+// valueColumns returns the column of the field's value and, if it has one, its label column
+func (f FieldSpec) valueColumns() []Column {
+	columns := []Column{{Name: f.Name, Title: f.Title}}
+	if f.LabelTitle != "" {
+		columns = append(columns, Column{Name: f.LabelName(), Title: f.LabelTitle})
+	}
+	return columns
+}
+
+// TotalName returns the record key of the field's computed total column
+func (f FieldSpec) TotalName() string {
+	return f.Name + "_TOTAL"
+}
+
+// LabelName returns the record key of the field's label column
+func (f FieldSpec) LabelName() string {
+	return f.Name + "_LABEL"
+}
+
+// Label returns the label of a value from the field's code set, or "" if it has none
+func (f FieldSpec) Label(value string) string {
+	if value == "" {
+		return ""
+	}
+	set, ok := LookupCodeSet(f.CodeSet)
+	if !ok {
+		return ""
+	}
+	label, _ := set.Label(value)
+	return label
+}
+
+// monthNames are the calendar months in SDR monthly breakdown order
+var monthNames = []string{
+	"January", "February", "March", "April", "May", "June",
+	"July", "August", "September", "October", "November", "December",
+}
+
+// monthlySubFields splits a packed monthly field into twelve equal sub-fields,
+// named <prefix>_01 to <prefix>_12 and titled "<titlePrefix> January" and so on
+func monthlySubFields(prefix, titlePrefix string, length int, fieldType FieldType, decimals int) []FieldSpec {
+	subFields := make([]FieldSpec, len(monthNames))
+	for i, month := range monthNames {
+		subFields[i] = FieldSpec{
+			Name:     fmt.Sprintf("%s_%02d", prefix, i+1),
+			Title:    fmt.Sprintf("%s %s", titlePrefix, month),
+			Start:    i*length + 1,
+			Length:   length,
+			Type:     fieldType,
+			Decimals: decimals,
+		}
+	}
+	return subFields
+}
+
+// repeatingSubFields splits a packed field holding up to count codes of the same kind
+// into sub-fields named <prefix>_1, <prefix>_2, ... and titled "<titlePrefix> 1" and so on,
+// each with a label column from the code set if it has one
+func repeatingSubFields(prefix, titlePrefix string, count, length int, codeSet string) []FieldSpec {
+	subFields := make([]FieldSpec, count)
+	for i := range subFields {
+		title := fmt.Sprintf("%s %d", titlePrefix, i+1)
+		subFields[i] = FieldSpec{
+			Name:    fmt.Sprintf("%s_%d", prefix, i+1),
+			Title:   title,
+			Start:   i*length + 1,
+			Length:  length,
+			Type:    FieldCode,
+			CodeSet: codeSet,
+		}
+		if codeSet != "" {
+			subFields[i].LabelTitle = title + " Label"
+		}
+	}
+	return subFields
+}
+
+// parseSubFields extracts the sub-fields of a packed field from a normalised line
+// into values, in column order followed by the total if the field has one.
+// Values are checked against their sub-field's type if checkType is set.
+// It returns the number of values set.
+func parseSubFields(values []string, field FieldSpec, line charLine, checkType bool) (int, error) {
+	var total float64
+	decimals := 0
+	column := 0
+
+	for _, sub := range field.SubFields {
+		// Sub-field positions are relative to the start of the parent field
+		start := field.Start - 1 + sub.Start - 1
+		end := start + sub.Length
+		if start < field.Start-1 || end > field.Start-1+field.Length || end > line.length() {
+			return 0, &LineError{
+				Field:  sub.Name,
+				Reason: fmt.Sprintf("sub-field %s lies outside field %s", sub.Name, field.Name),
+			}
+		}
+
+		value := strings.TrimSpace(line.slice(start, end))
+		if checkType && value != "" {
+			if err := sub.Check(value); err != nil {
+				return 0, &LineError{
+					Field:  sub.Name,
+					Reason: fmt.Sprintf("field %s: %v", sub.Name, err),
+				}
+			}
+		}
+		values[column] = value
+		column++
+		if sub.LabelTitle != "" {
+			values[column] = sub.Label(value)
+			column++
+		}
+
+		if sub.Type == FieldDecimal {
+			decimals = max(decimals, sub.Decimals)
+		}
+		if field.TotalTitle == "" || value == "" {
+			continue
+		}
+		switch sub.Type {
+		case FieldDecimal:
+			n, _ := sub.Decimal(value)
+			total += n
+		case FieldInteger:
+			n, _ := sub.Int(value)
+			total += float64(n)
+		}
+	}
+
+	if field.TotalTitle == "" {
+		return column, nil
+	}
+	values[column] = strconv.FormatFloat(total, 'f', decimals, 64)
+	return column + 1, nil
+}
@@ -1,25 +1,95 @@
 package parser
 
+import (
+	"fmt"
+	"io"
+)
+
 // FieldSpec defines a field in an SDR file
 type FieldSpec struct {
-	Name     string // Field name (e.g., "INSTIT")
-	Title    string // Descriptive title (e.g., "Provider Code")
-	Start    int    // 1-based starting position
-	Length   int    // Field length in characters
-	Required bool   // Whether field is required
+	Name     string    `json:"name"`               // Field name (e.g., "INSTIT")
+	Title    string    `json:"title"`              // Descriptive title (e.g., "Provider Code")
+	Start    int       `json:"start"`              // 1-based starting position
+	Length   int       `json:"length"`             // Field length in characters
+	Required bool      `json:"required,omitempty"` // Whether field is required
+	Type     FieldType `json:"type"`               // Kind of value (text when not set)
+	Decimals int       `json:"decimals,omitempty"` // Implied decimal places for decimal fields written without a point
+	// RightAlign pads the value on the left when writing fixed-width lines
+	RightAlign bool `json:"right_align,omitempty"`
+	// SubFields split a packed field into components, positioned relative to the field's start
+	SubFields []FieldSpec `json:"sub_fields,omitempty"`
+	// TotalTitle, if set, adds a computed column summing the numeric sub-fields
+	TotalTitle string `json:"total_title,omitempty"`
+	// CodeSet names the reference table of allowed values (see LookupCodeSet)
+	CodeSet string `json:"code_set,omitempty"`
+	// LabelTitle, if set, adds a column after the value holding its label from CodeSet
+	LabelTitle string `json:"label_title,omitempty"`
 }
 
 // FileSpec defines the structure of an SDR file type
 type FileSpec struct {
-	FileType    string      // STUD, COUR, CREG, etc.
-	Description string      // Human-readable description
-	LineLength  int         // Expected line length
-	Fields      []FieldSpec // Field definitions
+	FileType    string      `json:"file_type"`      // STUD, COUR, CREG, etc.
+	Description string      `json:"description"`    // Human-readable description
+	LineLength  int         `json:"line_length"`    // Expected line length
+	Fields      []FieldSpec `json:"fields"`         // Field definitions
+	Year        int         `json:"year,omitempty"` // First collection year the layout applies to (0 if not known)
+	Key         []string    `json:"key,omitempty"`  // Fields that identify a record; no two records of a file may share them
+}
+
+// ParseOptions controls how a parser reacts to lines it cannot parse
+type ParseOptions struct {
+	Lenient    bool     // Skip bad lines and collect them as LineErrors instead of aborting on the first
+	Strict     bool     // Reject lines that do not match the layout exactly, or hold values of the wrong type, instead of padding or truncating them
+	SourceName string   // File name recorded in the Source of each record
+	Encoding   Encoding // Character encoding of the input; detected when not set
+}
+
+// Source records where a parsed record came from
+type Source struct {
+	File   string // Name of the source file, empty if not known
+	Line   int    // 1-based line number, counting blank lines
+	Offset int64  // Byte offset of the start of the line
+	Raw    string // Raw text of the line
+}
+
+// String formats the source as "file:line"
+func (s Source) String() string {
+	if s.File == "" {
+		return fmt.Sprintf("line %d", s.Line)
+	}
+	return fmt.Sprintf("%s:%d", s.File, s.Line)
+}
+
+// LineError describes a line that could not be parsed
+type LineError struct {
+	Line   int    // 1-based line number
+	Offset int64  // Byte offset of the start of the line
+	Field  string // Field name, empty if the problem is not with a single field
+	Reason string // What is wrong (e.g., "required field ID is empty")
+	Raw    string // Raw text of the line
 }
 
+// Error formats the line error as "line N: reason"
+func (e *LineError) Error() string {
+	if e.Line == 0 {
+		return e.Reason
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+// RecordFunc receives each record parsed by Parser.ParseReader, with the line it came from.
+// The record's values are laid out as Parser.GetColumnNames. Returning an error stops parsing.
+type RecordFunc func(record Record, src Source) error
+
 // Parser interface for different file types
 type Parser interface {
-	Parse(content string) ([]map[string]string, error)
+	Parse(content string) ([]Record, error)
+	ParseReader(r io.Reader, fn RecordFunc) error
 	GetHeaders() []string
+	GetColumnNames() []string
 	GetFileType() string
+	SetOptions(opts ParseOptions)
+	GetLineErrors() []LineError
+	GetLineWarnings() []LineError
+	GetEncoding() Encoding
 }
@@ -0,0 +1,370 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// maxCodesListed limits how many allowed values a code-set finding lists
+const maxCodesListed = 12
+
+// eftsTolerance is how far EFTS values may differ before they are reported. Monthly
+// EFTS are rounded to four decimals, so twelve of them can drift by 12 × 0.00005.
+const eftsTolerance = 0.0006
+
+// Severity says how serious a validation finding is
+type Severity string
+
+const (
+	SeverityBlocking Severity = "blocking" // The return must not be uploaded until it is fixed
+	SeverityError    Severity = "error"    // The data is wrong and would be rejected or misreported
+	SeverityWarning  Severity = "warning"  // The data is unusual and deserves a look
+)
+
+// moreSevere reports whether s is more serious than other
+func (s Severity) moreSevere(other Severity) bool {
+	rank := map[Severity]int{SeverityWarning: 1, SeverityError: 2, SeverityBlocking: 3}
+	return rank[s] > rank[other]
+}
+
+// Finding is one problem reported by a validation check
+type Finding struct {
+	Rule     string   `json:"rule"` // Check that raised it (e.g., "code-set")
+	Severity Severity `json:"severity"`
+	FileType string   `json:"file_type"`
+	File     string   `json:"file"` // Source file name
+	Line     int      `json:"line"` // Line of the record, 0 when the finding is not about one line
+	Field    string   `json:"field"`
+	Value    string   `json:"value"`
+	Message  string   `json:"message"`
+}
+
+// String returns the finding as "file:line: FIELD: message"
+func (f Finding) String() string {
+	where := Source{File: f.File, Line: f.Line}.String()
+	if f.Line == 0 {
+		where = f.File
+	}
+	if where == "" {
+		return f.Message
+	}
+	if f.Field == "" {
+		return fmt.Sprintf("%s: %s", where, f.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", where, f.Field, f.Message)
+}
+
+// ruleTitles describes each rule for reports that group findings by rule
+var ruleTitles = map[string]string{
+	"file-error":       "Files that could not be read or parsed",
+	"parse":            "Lines that could not be parsed",
+	"line-warning":     "Lines that parsed but deserve a look",
+	"comparison":       "Problems loading data from related files",
+	"field-type":       "Values that do not match their field's type",
+	"code-set":         "Values not in their code table",
+	"course-dates":     "Courses that end before they start",
+	"withdrawal-date":  "Withdrawals outside the course",
+	"duplicate-key":    "Records that share their file's key",
+	"efts-sum":         "Monthly EFTS that do not add up to the course FACTOR",
+	"efts-months":      "EFTS in months outside the course",
+	"efts-factor":      "COUR FACTOR that differs from the CREG FACTOR of the course",
+	"missing-file":     "File types missing from the return",
+	"provider-return":  "Files of the return with different provider codes",
+	"provider-file":    "INSTIT values that differ from the provider code in the file name",
+	"provider-mixed":   "Files with records for more than one provider",
+	"student-age":      "Students implausibly young or old at the start of a course",
+	"completion-dates": "COMP end dates that differ from the COUR enrolment",
+	"cour-student":     "COUR enrolments of students not in STUD",
+	"cour-course":      "COUR courses not registered in CREG for the qualification",
+	"comp-enrolment":   "COMP completions without a matching COUR enrolment",
+	"qual-enrolment":   "QUAL completions without COUR enrolments in the qualification",
+}
+
+// FindingGroup is the findings of one rule: how many there are and a sample of them
+type FindingGroup struct {
+	Rule     string
+	Title    string   // What the rule checks
+	Severity Severity // Most serious severity among the findings
+	Count    int
+	Samples  []Finding // The first findings, in report order
+}
+
+// GroupFindings groups findings by rule, in order of each rule's first finding,
+// keeping up to samples findings of each
+func GroupFindings(findings []Finding, samples int) []FindingGroup {
+	var groups []FindingGroup
+	positions := make(map[string]int) // Rule -> group
+	for _, finding := range findings {
+		i, ok := positions[finding.Rule]
+		if !ok {
+			i = len(groups)
+			positions[finding.Rule] = i
+			groups = append(groups, FindingGroup{
+				Rule:     finding.Rule,
+				Title:    ruleTitle(finding.Rule),
+				Severity: finding.Severity,
+			})
+		}
+
+		group := &groups[i]
+		group.Count++
+		if finding.Severity.moreSevere(group.Severity) {
+			group.Severity = finding.Severity
+		}
+		if len(group.Samples) < samples {
+			group.Samples = append(group.Samples, finding)
+		}
+	}
+	return groups
+}
+
+// Validator checks the records of one file as they are parsed and collects findings
+type Validator struct {
+	spec     FileSpec
+	year     int // Collection year of the records, 0 if unknown
+	findings []Finding
+	keys     *keyIndex
+}
+
+// NewValidator creates a validator for records of the given spec
+func NewValidator(spec FileSpec) *Validator {
+	return &Validator{spec: spec, keys: newKeyIndex()}
+}
+
+// SetYear sets the collection year of the records, the year of their monthly EFTS
+func (v *Validator) SetYear(year int) {
+	v.year = year
+}
+
+// Check checks one record. It is a RecordFunc, so it can be passed to ParseReader or
+// set as CSVWriter.OnRecord; it never stops parsing.
+func (v *Validator) Check(record Record, src Source) error {
+	v.checkFieldTypes(record, src)
+	v.checkCodeSets(record, src)
+	v.checkCourseDates(record, src)
+	v.checkEFTS(record, src)
+	v.checkRules(record, src)
+	if key, ok := v.spec.KeyOf(record); ok {
+		v.keys.add(key, src)
+	}
+	return nil
+}
+
+// Findings returns the findings so far, in the order of the records checked,
+// followed by one finding for each group of records that share a key
+func (v *Validator) Findings() []Finding {
+	findings := v.findings[:len(v.findings):len(v.findings)]
+	for _, group := range v.keys.duplicates() {
+		findings = append(findings, Finding{
+			Rule:     "duplicate-key",
+			Severity: SeverityError,
+			FileType: v.spec.FileType,
+			File:     group.sources[0].File,
+			Line:     group.sources[0].Line,
+			Field:    strings.Join(v.spec.Key, "+"),
+			Value:    strings.ReplaceAll(group.key, "||", " "),
+			Message: fmt.Sprintf("%s is the key of %d records, on lines %s",
+				strings.ReplaceAll(group.key, "||", ", "), len(group.sources), listLines(group.sources)),
+		})
+	}
+	return findings
+}
+
+// report adds a finding about a record
+func (v *Validator) report(rule string, severity Severity, src Source, field, value, message string) {
+	v.findings = append(v.findings, Finding{
+		Rule:     rule,
+		Severity: severity,
+		FileType: v.spec.FileType,
+		File:     src.File,
+		Line:     src.Line,
+		Field:    field,
+		Value:    value,
+		Message:  message,
+	})
+}
+
+// checkFieldTypes reports every value, sub-fields included, that does not match its field's
+// type, such as a date that is not a real DDMMYYYY date. Outside strict mode the parser keeps them.
+func (v *Validator) checkFieldTypes(record Record, src Source) {
+	for _, field := range v.spec.Fields {
+		for _, f := range append([]FieldSpec{field}, field.SubFields...) {
+			value := record.Get(f.Name)
+			if value == "" {
+				continue
+			}
+			if err := f.Check(value); err != nil {
+				v.report("field-type", SeverityError, src, f.Name, value, fmt.Sprintf("field %s: %v", f.Name, err))
+			}
+		}
+	}
+}
+
+// checkCodeSets reports every coded value, sub-fields included, that is not in its field's code set.
+// A code set with no codes is a table that is not bundled, so its values are not checked.
+func (v *Validator) checkCodeSets(record Record, src Source) {
+	for _, field := range v.spec.Fields {
+		for _, f := range append([]FieldSpec{field}, field.SubFields...) {
+			if f.CodeSet == "" {
+				continue
+			}
+			value := record.Get(f.Name)
+			set, ok := LookupCodeSet(f.CodeSet)
+			if value == "" || !ok || len(set.Codes) == 0 || set.Contains(value) {
+				continue
+			}
+			v.report("code-set", SeverityError, src, f.Name, value,
+				fmt.Sprintf("%q is not in code set %s (allowed: %s)", value, set.Name, listCodes(set)))
+		}
+	}
+}
+
+// listCodes lists the codes of a set for a message, shortened for long sets
+func listCodes(set CodeSet) string {
+	codes := set.Values()
+	if len(codes) <= maxCodesListed {
+		return strings.Join(codes, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(codes[:maxCodesListed], ", "), len(codes)-maxCodesListed)
+}
+
+// checkCourseDates reports a course that ends before it starts, and a withdrawal outside the course
+func (v *Validator) checkCourseDates(record Record, src Source) {
+	start, hasStart := v.date(record, "CRS_SRT")
+	end, hasEnd := v.date(record, "CRS_END")
+	if hasStart && hasEnd && end.Before(start) {
+		v.report("course-dates", SeverityError, src, "CRS_END", record.Get("CRS_END"),
+			fmt.Sprintf("course ends on %s, before it starts on %s", record.Get("CRS_END"), record.Get("CRS_SRT")))
+	}
+
+	withdrawn, ok := v.date(record, "CRS_WTD")
+	if !ok {
+		return
+	}
+	if (hasStart && withdrawn.Before(start)) || (hasEnd && withdrawn.After(end)) {
+		v.report("withdrawal-date", SeverityError, src, "CRS_WTD", record.Get("CRS_WTD"),
+			fmt.Sprintf("withdrawal on %s is outside the course (%s to %s)",
+				record.Get("CRS_WTD"), record.Get("CRS_SRT"), record.Get("CRS_END")))
+	}
+}
+
+// date returns the named date field of a record, if the spec has it and it is set
+func (v *Validator) date(record Record, name string) (time.Time, bool) {
+	if record.Get(name) == "" {
+		return time.Time{}, false
+	}
+	date, err := v.spec.Date(record, name)
+	return date, err == nil
+}
+
+// keyIndex records where each key was seen, to find records that share one
+type keyIndex struct {
+	sources  map[string][]Source
+	repeated []string // Keys seen more than once, in order of their second record
+}
+
+// keyGroup is the records that share a key
+type keyGroup struct {
+	key     string
+	sources []Source
+}
+
+func newKeyIndex() *keyIndex {
+	return &keyIndex{sources: make(map[string][]Source)}
+}
+
+// add records a key and where it was seen. It reports whether the key was seen before.
+func (k *keyIndex) add(key string, src Source) bool {
+	src.Raw = "" // Only the position is reported
+	k.sources[key] = append(k.sources[key], src)
+	if len(k.sources[key]) == 2 {
+		k.repeated = append(k.repeated, key)
+	}
+	return len(k.sources[key]) > 1
+}
+
+// duplicates returns every key seen more than once, with all of its records
+func (k *keyIndex) duplicates() []keyGroup {
+	groups := make([]keyGroup, len(k.repeated))
+	for i, key := range k.repeated {
+		groups[i] = keyGroup{key: key, sources: k.sources[key]}
+	}
+	return groups
+}
+
+// listLines lists the line numbers of records
+func listLines(sources []Source) string {
+	lines := make([]string, len(sources))
+	for i, src := range sources {
+		lines[i] = fmt.Sprint(src.Line)
+	}
+	return strings.Join(lines, ", ")
+}
+
+// checkEFTS reports EFTS in months the course does not run, and monthly EFTS that do
+// not add up to FACTOR. The months are those of one collection year, so only a course
+// that starts and ends in the same year must add up exactly; a longer course may
+// report the rest of its FACTOR in another year's return, but never more than all of it.
+// Without a collection year, the months of a longer course cannot be placed.
+func (v *Validator) checkEFTS(record Record, src Source) {
+	months, ok := v.spec.Field("EFTS_MTH")
+	if !ok || len(months.SubFields) == 0 {
+		return
+	}
+	start, hasStart := v.date(record, "CRS_SRT")
+	end, hasEnd := v.date(record, "CRS_END")
+	if hasStart && hasEnd && end.Before(start) {
+		hasStart, hasEnd = false, false // Reported by checkCourseDates; the months cannot be placed
+	}
+	year := v.year
+	if year == 0 && hasStart && hasEnd && start.Year() == end.Year() {
+		year = start.Year()
+	}
+
+	var sum float64
+	for i, month := range months.SubFields {
+		value := record.Get(month.Name)
+		if value == "" {
+			continue
+		}
+		efts, err := month.Decimal(value)
+		if err != nil || efts == 0 {
+			continue
+		}
+		sum += efts
+
+		if hasStart && hasEnd && year != 0 && !monthInCourse(year, time.Month(i+1), start, end) {
+			v.report("efts-months", SeverityError, src, month.Name, value,
+				fmt.Sprintf("EFTS %s in %s, outside the course (%s to %s)",
+					value, monthNames[i], record.Get("CRS_SRT"), record.Get("CRS_END")))
+		}
+	}
+
+	if record.Get("FACTOR") == "" {
+		return
+	}
+	factor, err := v.spec.Decimal(record, "FACTOR")
+	if err != nil {
+		return
+	}
+
+	difference := sum - factor
+	if hasStart && hasEnd && start.Year() == end.Year() {
+		if math.Abs(difference) > eftsTolerance {
+			v.report("efts-sum", SeverityError, src, "FACTOR", record.Get("FACTOR"),
+				fmt.Sprintf("monthly EFTS add up to %.4f, but FACTOR is %.4f (difference %+.4f)", sum, factor, difference))
+		}
+	} else if difference > eftsTolerance {
+		v.report("efts-sum", SeverityError, src, "FACTOR", record.Get("FACTOR"),
+			fmt.Sprintf("monthly EFTS add up to %.4f, more than FACTOR %.4f (difference %+.4f)", sum, factor, difference))
+	}
+}
+
+// monthInCourse reports whether the course runs during the month of the year
+func monthInCourse(year int, month time.Month, start, end time.Time) bool {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1)
+	return !last.Before(start) && !first.After(end)
+}
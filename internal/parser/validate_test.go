@@ -0,0 +1,232 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidator_SamplesHaveNoFindings(t *testing.T) {
+	samples := map[string]string{"STUD": sampleSTUDCodedData} // sampleSTUDData has made-up ethnicity codes
+	for fileType, content := range sniffSamples {
+		if _, ok := samples[fileType]; !ok {
+			samples[fileType] = content
+		}
+	}
+	for fileType, content := range samples {
+		reg, _ := LookupFileType(fileType)
+		validator := NewValidator(reg.Spec)
+		parser := reg.NewParser(reg.Spec)
+		if err := parser.ParseReader(strings.NewReader(content), validator.Check); err != nil {
+			t.Fatalf("%s: ParseReader failed: %v", fileType, err)
+		}
+		if findings := validator.Findings(); len(findings) != 0 {
+			t.Errorf("%s: expected no findings, got %v", fileType, findings)
+		}
+	}
+}
+
+func TestProcessFileWithOptions_CodeSetFindings(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "COUR9170.txt")
+
+	// Line 2 has FUNDING "XX" (positions 70-71) and ATTEND "7" (position 67), for another student
+	line := sniffSamples["COUR"]
+	bad := withField(CourseEnrolmentSpec, line[:66]+"7"+line[67:69]+"XX"+line[71:], "ID", "917000048")
+	if err := os.WriteFile(inputPath, []byte(line+"\n"+bad+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	// Findings are only collected when asked for
+	if result := ProcessFile(inputPath, dir); len(result.Findings) != 0 {
+		t.Errorf("Expected no findings without validation, got %v", result.Findings)
+	}
+
+	result := ProcessFileWithOptions(inputPath, dir, ProcessOptions{Validate: true})
+	if !result.Success || result.RecordCount != 2 {
+		t.Fatalf("Expected 2 records converted, got %d (%v)", result.RecordCount, result.Error)
+	}
+	if len(result.Findings) != 2 {
+		t.Fatalf("Expected 2 findings, got %v", result.Findings)
+	}
+
+	attend, funding := result.Findings[0], result.Findings[1]
+	if attend.Line != 2 || attend.Field != "ATTEND" || attend.Value != "7" || attend.Rule != "code-set" || attend.Severity != SeverityError {
+		t.Errorf("Unexpected ATTEND finding: %+v", attend)
+	}
+	if funding.Field != "FUNDING" || !strings.Contains(funding.Message, "allowed: 01, 02") {
+		t.Errorf("Unexpected FUNDING finding: %+v", funding)
+	}
+	if expected := `COUR9170.txt:2: ATTEND: "7" is not in code set ATTEND (allowed: 1, 2)`; attend.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, attend.String())
+	}
+}
+
+func TestLoadCodeSetDir_OverridesBuiltInTable(t *testing.T) {
+	saved := codeSets
+	defer func() { codeSets = saved }()
+
+	dir := t.TempDir()
+	if _, err := ExportCodeSets(dir); err != nil {
+		t.Fatalf("ExportCodeSets failed: %v", err)
+	}
+
+	// Add a code to the exported ATTEND table, as a data manager would after a TEC change
+	path := filepath.Join(dir, "attend.csv")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read exported table: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, "3,Blended\n"...), 0644); err != nil {
+		t.Fatalf("Failed to write table: %v", err)
+	}
+
+	if _, err := LoadCodeSetDir(dir); err != nil {
+		t.Fatalf("LoadCodeSetDir failed: %v", err)
+	}
+	attend, _ := LookupCodeSet("ATTEND")
+	if label, ok := attend.Label("3"); !ok || label != "Blended" || !attend.Contains("1") {
+		t.Errorf("Expected ATTEND to gain code 3, got %v", attend.Codes)
+	}
+
+	// Exported tables round-trip unchanged
+	country, _ := LookupCodeSet("COUNTRY")
+	if label, _ := country.Label("NZL"); label != "New Zealand" || len(country.Codes) != len(saved["COUNTRY"].Codes) {
+		t.Errorf("Unexpected COUNTRY table after reload: %d codes, NZL %q", len(country.Codes), label)
+	}
+}
+
+func TestLoadCodeSetDir_InvalidTable(t *testing.T) {
+	saved := codeSets
+	defer func() { codeSets = saved }()
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "attend.csv"), []byte("Code,Label\n1,Intramural\n3,Blended\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "gender.csv"), []byte("Code,Label\nF,Female\nF,Female again\n"), 0644)
+
+	_, err := LoadCodeSetDir(dir)
+	if err == nil || !strings.Contains(err.Error(), "line 3: code F is listed more than once") {
+		t.Fatalf("Expected duplicate code error, got: %v", err)
+	}
+
+	// Nothing is installed, not even the valid table
+	if attend, _ := LookupCodeSet("ATTEND"); attend.Contains("3") {
+		t.Error("Expected the built-in ATTEND table to remain")
+	}
+}
+
+func TestValidator_DuplicateKeys(t *testing.T) {
+	lines := strings.Split(sampleCREGData, "\n")
+	content := strings.Join([]string{lines[0], lines[1], lines[0], lines[2], lines[1], lines[0]}, "\n")
+
+	validator := NewValidator(GetCREGSpec())
+	parser := NewCREGParser()
+	parser.SetOptions(ParseOptions{SourceName: "CREG9170.txt"})
+	if err := parser.ParseReader(strings.NewReader(content), validator.Check); err != nil {
+		t.Fatalf("ParseReader failed: %v", err)
+	}
+
+	findings := validator.Findings()
+	if len(findings) != 2 {
+		t.Fatalf("Expected 2 duplicate groups, got %v", findings)
+	}
+	if expected := "CREG9170.txt:1: INSTIT+COURSE: 9170, 2102-530 is the key of 3 records, on lines 1, 3, 6"; findings[0].String() != expected {
+		t.Errorf("Expected %q, got %q", expected, findings[0].String())
+	}
+	if findings[1].Rule != "duplicate-key" || findings[1].Line != 2 || findings[1].Value != "9170 2102-510" {
+		t.Errorf("Unexpected second group: %+v", findings[1])
+	}
+}
+
+func TestValidator_CourseDates(t *testing.T) {
+	line := sniffSamples["COUR"]
+	student := func(id string) string { return withField(CourseEnrolmentSpec, line, "ID", id) }
+	content := strings.Join([]string{
+		line,
+		withField(CourseEnrolmentSpec, student("917000048"), "CRS_END", "01012023"), // Ends before it starts
+		withField(CourseEnrolmentSpec, student("917000049"), "CRS_WTD", "01072024"), // Withdrawn after the end
+		withField(CourseEnrolmentSpec, student("917000050"), "CRS_WTD", "01102023"), // Withdrawn during the course
+	}, "\n")
+
+	validator := NewValidator(CourseEnrolmentSpec)
+	if err := NewCourseEnrolmentParser().ParseReader(strings.NewReader(content), validator.Check); err != nil {
+		t.Fatalf("ParseReader failed: %v", err)
+	}
+
+	findings := validator.Findings()
+	if len(findings) != 2 {
+		t.Fatalf("Expected 2 findings, got %v", findings)
+	}
+	if findings[0].Rule != "course-dates" || findings[0].Line != 2 || findings[0].Field != "CRS_END" {
+		t.Errorf("Unexpected course date finding: %+v", findings[0])
+	}
+	if findings[1].Rule != "withdrawal-date" || findings[1].Line != 3 || findings[1].Value != "01072024" {
+		t.Errorf("Unexpected withdrawal finding: %+v", findings[1])
+	}
+}
+
+func TestValidator_EFTS(t *testing.T) {
+	line := sniffSamples["COUR"] // EFTS January to June adding up to 0.0699 of FACTOR 0.1167, from 28092023 to 06062024
+	inYear := func(start, end string) string {
+		return withField(CourseEnrolmentSpec, withField(CourseEnrolmentSpec, line, "CRS_SRT", start), "CRS_END", end)
+	}
+	content := strings.Join([]string{
+		line,                           // The rest of FACTOR was reported in 2023
+		inYear("01022024", "30062024"), // EFTS in January; adds up to less than FACTOR
+		withField(CourseEnrolmentSpec, line, "FACTOR", "0.0500"),                           // More EFTS than the whole course
+		withField(CourseEnrolmentSpec, inYear("08012024", "06062024"), "FACTOR", "0.0700"), // Off by rounding only
+	}, "\n")
+
+	validator := NewValidator(CourseEnrolmentSpec)
+	if err := NewCourseEnrolmentParser().ParseReader(strings.NewReader(content), validator.Check); err != nil {
+		t.Fatalf("ParseReader failed: %v", err)
+	}
+
+	months := findingsByRule(validator.Findings(), "efts-months")
+	if len(months) != 1 || months[0].Line != 2 || months[0].Field != "EFTS_MTH_01" || !strings.Contains(months[0].Message, "in January") {
+		t.Errorf("Unexpected month findings: %v", months)
+	}
+
+	sums := findingsByRule(validator.Findings(), "efts-sum")
+	if len(sums) != 2 {
+		t.Fatalf("Expected 2 sum findings, got %v", sums)
+	}
+	if sums[0].Line != 2 || !strings.Contains(sums[0].Message, "add up to 0.0699, but FACTOR is 0.1167 (difference -0.0468)") {
+		t.Errorf("Unexpected in-year sum finding: %+v", sums[0])
+	}
+	if sums[1].Line != 3 || !strings.Contains(sums[1].Message, "more than FACTOR 0.0500 (difference +0.0199)") {
+		t.Errorf("Unexpected excess sum finding: %+v", sums[1])
+	}
+}
+
+func TestValidator_EFTSCollectionYear(t *testing.T) {
+	// A course from September 2023 to June 2024, with EFTS January to June and in October
+	line := sniffSamples["COUR"]
+	october := 93 + 9*7 - 1 // EFTS_MTH starts at 93 with seven characters a month
+	line = line[:october] + "0.0117 " + line[october+7:]
+
+	tests := []struct {
+		year   int
+		months []string // Fields of the efts-months findings
+	}{
+		{2024, []string{"EFTS_MTH_10"}}, // October 2024 is after the course
+		{2023, []string{"EFTS_MTH_01", "EFTS_MTH_02", "EFTS_MTH_03", "EFTS_MTH_04", "EFTS_MTH_05", "EFTS_MTH_06"}},
+		{0, nil}, // Without a collection year, the months of a two-year course cannot be placed
+	}
+	for _, test := range tests {
+		validator := NewValidator(CourseEnrolmentSpec)
+		validator.SetYear(test.year)
+		if err := NewCourseEnrolmentParser().ParseReader(strings.NewReader(line), validator.Check); err != nil {
+			t.Fatalf("ParseReader failed: %v", err)
+		}
+
+		var fields []string
+		for _, finding := range findingsByRule(validator.Findings(), "efts-months") {
+			fields = append(fields, finding.Field)
+		}
+		if strings.Join(fields, ",") != strings.Join(test.months, ",") {
+			t.Errorf("%d: expected findings for %v, got %v", test.year, test.months, fields)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package parser
+
+// Specs returns every layout of the file type, the current spec first
+func (r FileTypeRegistration) Specs() []FileSpec {
+	return append([]FileSpec{r.Spec}, r.Versions...)
+}
+
+// SpecForYear returns the layout in force for a collection year: the spec with
+// the latest Year not after it. A year of 0 means the current spec.
+func (r FileTypeRegistration) SpecForYear(year int) FileSpec {
+	if year == 0 {
+		return r.Spec
+	}
+
+	best := FileSpec{}
+	found := false
+	for _, spec := range r.Specs() {
+		if spec.Year > year {
+			continue
+		}
+		if !found || spec.Year > best.Year {
+			best = spec
+			found = true
+		}
+	}
+
+	// Years before the earliest known layout use the earliest one
+	if !found {
+		best = r.Spec
+		for _, spec := range r.Versions {
+			if spec.Year < best.Year {
+				best = spec
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTestLayouts installs a dated current STUD layout and an earlier one in which NSN
+// comes before IRDNOS, for the rest of a test
+func useTestLayouts(t *testing.T) {
+	t.Helper()
+	saved := RegisteredFileTypes()
+	t.Cleanup(func() { registry = saved })
+
+	current := GetSTUDSpec()
+	current.Year = 2012
+	earlier := GetSTUDSpec()
+	earlier.Year = 2005
+	earlier.Fields = append([]FieldSpec{}, earlier.Fields...)
+	for i, field := range earlier.Fields {
+		switch field.Name {
+		case "NSN":
+			earlier.Fields[i].Start = 71
+		case "IRDNOS":
+			earlier.Fields[i].Start = 81
+		}
+	}
+	for _, spec := range []FileSpec{current, earlier} {
+		if err := spec.Validate(); err != nil {
+			t.Fatalf("Invalid test layout: %v", err)
+		}
+		UseSpec(spec)
+	}
+}
+
+func TestSpecForYear(t *testing.T) {
+	useTestLayouts(t)
+	reg, ok := LookupFileType("STUD")
+	if !ok {
+		t.Fatal("STUD is not registered")
+	}
+
+	tests := []struct {
+		year     int
+		expected int // Start of the NSN field
+	}{
+		{0, 80},
+		{2012, 80},
+		{2024, 80},
+		{2011, 71},
+		{2005, 71},
+	}
+
+	for _, test := range tests {
+		field, _ := reg.SpecForYear(test.year).Field("NSN")
+		if field.Start != test.expected {
+			t.Errorf("Year %d: expected NSN to start at %d, got %d", test.year, test.expected, field.Start)
+		}
+	}
+
+	// Types without earlier layouts always use the current one
+	creg, _ := LookupFileType("CREG")
+	if spec := creg.SpecForYear(2005); spec.Description != GetCREGSpec().Description {
+		t.Errorf("Expected current CREG spec, got '%s'", spec.Description)
+	}
+}
+
+func TestProcessFileWithOptions_Year(t *testing.T) {
+	useTestLayouts(t)
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "STUD2010.txt")
+	line := strings.Split(sampleSTUDData, "\n")[0]
+	if err := os.WriteFile(inputPath, []byte(line), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	for _, test := range []struct {
+		year     int
+		expected string
+	}{
+		{2010, strings.TrimSpace(line[70:80])},
+		{0, strings.TrimSpace(line[79:89])},
+	} {
+		result := ProcessFileWithOptions(inputPath, dir, ProcessOptions{Year: test.year})
+		if !result.Success {
+			t.Fatalf("Expected success, got: %v", result.Error)
+		}
+		if result.Year != test.year {
+			t.Errorf("Expected year %d, got %d", test.year, result.Year)
+		}
+
+		rows := readCSV(t, result.OutputFile)
+		for i, header := range rows[0] {
+			if header == "National Student Number" && rows[1][i] != test.expected {
+				t.Errorf("Year %d: expected NSN %q, got %q", test.year, test.expected, rows[1][i])
+			}
+		}
+	}
+}
@@ -50,7 +50,7 @@ func (m FilePickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	if didSelect, path := m.filepicker.DidSelectDisabledFile(msg); didSelect {
-		m.err = fmt.Errorf("selected file is not a .txt file: %s", path)
+		m.err = fmt.Errorf("selected file is not a %s file: %s", strings.Join(m.filepicker.AllowedTypes, " or "), path)
 	}
 
 	return m, cmd
@@ -61,7 +61,9 @@ func (m FilePickerModel) View() string {
 	
 	// Header with filter info
 	header := ">> SELECT AN SDR FILE TO PARSE <<"
-	if m.filterType != "" && m.filterType != "all" {
+	if m.filterType == rebuildOption {
+		header = ">> SELECT A CSV FILE TO REBUILD <<"
+	} else if m.filterType != "" && m.filterType != "all" {
 		header = fmt.Sprintf(">> SELECT A %s FILE TO PARSE <<", strings.ToUpper(m.filterType))
 	}
 	s.WriteString(styles.HighlightStyle.Render(header) + "\n\n")
@@ -84,4 +86,7 @@ func (m FilePickerModel) View() string {
 // SetFilter sets the file type filter for the picker
 func (m *FilePickerModel) SetFilter(fileType string) {
 	m.filterType = fileType
+	if fileType == rebuildOption {
+		m.filepicker.AllowedTypes = []string{".csv"}
+	}
 }
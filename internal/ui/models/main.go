@@ -5,6 +5,7 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/unamelo/oh-no-sdr/internal/parser"
 	"github.com/unamelo/oh-no-sdr/internal/ui/styles"
 )
 
@@ -31,17 +32,28 @@ type MainModel struct {
 	height          int
 	currentFileType string
 	filesToProcess  []string
-	animationFrame  int // Add animation state
+	animationFrame  int                   // Add animation state
+	defaults        parser.ProcessOptions // Year and encoding given on the command line
 }
 
 func NewMainModel() MainModel {
+	return NewMainModelWithOptions(parser.ProcessOptions{})
+}
+
+// NewMainModelWithOptions creates the main model with the collection year and
+// encoding preset, e.g. from command line flags (zero values detect them from the data)
+func NewMainModelWithOptions(defaults parser.ProcessOptions) MainModel {
+	menu := NewMenuModel()
+	menu.SetDefaults(defaults)
+
 	return MainModel{
 		state:          menuView,
-		menu:           NewMenuModel(),
+		menu:           menu,
 		filePicker:     NewFilePickerModel(),
 		progress:       NewProgressModel(),
 		results:        NewResultsModel(),
 		animationFrame: 0,
+		defaults:       defaults,
 	}
 }
 
@@ -98,11 +110,19 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.currentFileType = fileType
 			m.filesToProcess = files
 
+			// A return is the files in this folder, so there is nothing to pick
+			if fileType == validateReturnOption {
+				m.state = processingView
+				return m, tea.Batch(cmd, m.progress.StartValidatingReturn(files, m.menu.GetProcessOptions()))
+			}
+
 			// If files were found, go directly to processing
 			if len(files) > 0 {
 				m.state = processingView
-				comparisonEnabled := m.menu.GetGenerateComparison()
-				return m, tea.Batch(cmd, m.progress.StartProcessingMultipleWithComparison(files, comparisonEnabled))
+				if fileType == rebuildOption {
+					return m, tea.Batch(cmd, m.progress.StartRebuildingWithOptions(files, m.menu.GetProcessOptions()))
+				}
+				return m, tea.Batch(cmd, m.progress.StartProcessingMultipleWithOptions(files, m.menu.GetProcessOptions()))
 			} else {
 				// No files found, show file picker
 				m.state = filePickerView
@@ -121,8 +141,10 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Check if file was selected
 		if m.filePicker.selectedFile != "" {
 			m.state = processingView
-			comparisonEnabled := m.menu.GetGenerateComparison()
-			return m, tea.Batch(cmd, m.progress.StartProcessingWithComparison(m.filePicker.selectedFile, comparisonEnabled))
+			if m.currentFileType == rebuildOption {
+				return m, tea.Batch(cmd, m.progress.StartRebuildingWithOptions([]string{m.filePicker.selectedFile}, m.menu.GetProcessOptions()))
+			}
+			return m, tea.Batch(cmd, m.progress.StartProcessingWithOptions(m.filePicker.selectedFile, m.menu.GetProcessOptions()))
 		}
 		return m, cmd
 
@@ -145,6 +167,7 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.results.backToMenu {
 			m.state = menuView
 			m.menu = NewMenuModel()
+			m.menu.SetDefaults(m.defaults)
 			m.results.backToMenu = false
 			return m, m.menu.Init()
 		}
@@ -5,34 +5,66 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/unamelo/oh-no-sdr/internal/parser"
 	"github.com/unamelo/oh-no-sdr/internal/ui/styles"
 )
 
+// menuCheckbox is a toggleable option listed below the menu choices
+type menuCheckbox struct {
+	label   string
+	checked bool
+}
+
+// Checkbox positions in MenuModel.checkboxes
+const (
+	checkboxComparison = iota
+	checkboxSkipBadLines
+	checkboxSourceColumns
+	checkboxStrict
+	checkboxValidate
+)
+
 type MenuModel struct {
 	choices       []string
+	fileTypes     []string // File type behind each choice after "Parse All Files"
 	cursor        int
 	selectedIndex int
 	width         int
 	height        int
-	// Checkbox for comparison data generation
-	generateComparison bool
+	// Checkboxes for processing options, navigated after the choices
+	checkboxes []menuCheckbox
+	// Collection year selector, navigated after the checkboxes (0 uses the current layouts)
+	year int
+	// Character encoding of SDR files, set from the command line (detected when empty)
+	encoding parser.Encoding
 }
 
 func NewMenuModel() MenuModel {
+	choices := []string{"Parse All Files"}
+	var fileTypes []string
+
+	// One option per registered SDR file type
+	for _, reg := range parser.RegisteredFileTypes() {
+		choices = append(choices, fmt.Sprintf("Parse %s File", reg.Spec.FileType))
+		fileTypes = append(fileTypes, reg.Spec.FileType)
+	}
+	choices = append(choices, "Rebuild SDR File from CSV", "Validate SDR Return (all files)")
+
 	return MenuModel{
-		choices: []string{
-			"Parse All Files",
-			"Parse STUD File",
-			"Parse COUR File",
-			"Parse CREG File",
-			"Parse COMP File",
-			"Parse QUAL File",
+		choices:       choices,
+		fileTypes:     fileTypes,
+		selectedIndex: -1,
+		checkboxes: []menuCheckbox{
+			checkboxComparison:    {label: "Generate comparison data", checked: true}, // Default to checked
+			checkboxSkipBadLines:  {label: "Skip bad lines and report them"},
+			checkboxSourceColumns: {label: "Add source line columns"},
+			checkboxStrict:        {label: "Strict layout check (pre-submission)"},
+			checkboxValidate:      {label: "Validate records"},
 		},
-		selectedIndex:      -1,
-		generateComparison: true, // Default to checked
 	}
 }
 
@@ -51,20 +83,28 @@ func (m MenuModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.cursor--
 			}
 		case "down", "j":
-			// Total items = choices + 1 (for the checkbox)
-			if m.cursor < len(m.choices) {
+			// Total items = choices + checkboxes + year selector
+			if m.cursor < m.yearPosition() {
 				m.cursor++
 			}
+		case "left", "h":
+			if m.cursor == m.yearPosition() {
+				m.year = previousYear(m.year)
+			}
+		case "right", "l":
+			if m.cursor == m.yearPosition() {
+				m.year = nextYear(m.year)
+			}
 		case "enter":
-			// Only select if cursor is on a choice (not the checkbox)
+			// Only select if cursor is on a choice (not a checkbox)
 			if m.cursor < len(m.choices) {
 				m.selectedIndex = m.cursor
 				return m, nil
 			}
 		case " ":
-			// Toggle checkbox if cursor is on it
-			if m.cursor == len(m.choices) {
-				m.generateComparison = !m.generateComparison
+			// Toggle checkbox if cursor is on one
+			if i := m.cursor - len(m.choices); i >= 0 && i < len(m.checkboxes) {
+				m.checkboxes[i].checked = !m.checkboxes[i].checked
 			}
 		}
 	}
@@ -100,29 +140,42 @@ func (m MenuModel) View() string {
 	optionsHeader := styles.HighlightStyle.Render("OPTIONS:")
 	s.WriteString(optionsHeader + "\n")
 
-	// Checkbox for comparison data generation
-	checkboxCursor := " "
-	if m.cursor == len(m.choices) {
-		checkboxCursor = ">"
-		checkboxCursor = lipgloss.NewStyle().Foreground(styles.Primary).Render(checkboxCursor)
-	}
+	// Checkboxes for processing options
+	for i, checkbox := range m.checkboxes {
+		position := len(m.choices) + i
 
-	checkboxIcon := "☐"
-	if m.generateComparison {
-		checkboxIcon = "☑"
-	}
+		checkboxCursor := " "
+		if m.cursor == position {
+			checkboxCursor = ">"
+			checkboxCursor = lipgloss.NewStyle().Foreground(styles.Primary).Render(checkboxCursor)
+		}
+
+		checkboxIcon := "☐"
+		if checkbox.checked {
+			checkboxIcon = "☑"
+		}
 
-	checkboxText := "Generate comparison data"
-	if m.cursor == len(m.choices) {
-		checkboxText = styles.HighlightStyle.Render(checkboxText)
+		checkboxText := checkbox.label
+		if m.cursor == position {
+			checkboxText = styles.HighlightStyle.Render(checkboxText)
+		}
+
+		s.WriteString(fmt.Sprintf("%s %s %s\n", checkboxCursor, checkboxIcon, checkboxText))
 	}
 
-	s.WriteString(fmt.Sprintf("%s %s %s\n", checkboxCursor, checkboxIcon, checkboxText))
+	// Collection year selector
+	yearCursor := " "
+	yearText := "Collection year: " + yearLabel(m.year)
+	if m.cursor == m.yearPosition() {
+		yearCursor = lipgloss.NewStyle().Foreground(styles.Primary).Render(">")
+		yearText = styles.HighlightStyle.Render("Collection year: ◀ " + yearLabel(m.year) + " ▶")
+	}
+	s.WriteString(fmt.Sprintf("%s %s\n", yearCursor, yearText))
 
 	s.WriteString("\n")
 
 	// Instructions
-	instructions := styles.SubtitleStyle.Render("CONTROLS: [↑/↓] Navigate • [Enter] Select • [Space] Toggle • [q] Quit")
+	instructions := styles.SubtitleStyle.Render("CONTROLS: [↑/↓] Navigate • [Enter] Select • [Space] Toggle • [←/→] Year • [q] Quit")
 	s.WriteString(instructions)
 
 	return styles.BoxStyle.Render(s.String())
@@ -130,7 +183,55 @@ func (m MenuModel) View() string {
 
 // GetGenerateComparison returns the current state of the comparison checkbox
 func (m MenuModel) GetGenerateComparison() bool {
-	return m.generateComparison
+	return m.checkboxes[checkboxComparison].checked
+}
+
+// GetProcessOptions returns the processing options selected with the checkboxes and year selector
+func (m MenuModel) GetProcessOptions() parser.ProcessOptions {
+	return parser.ProcessOptions{
+		EnableComparison: m.checkboxes[checkboxComparison].checked,
+		Lenient:          m.checkboxes[checkboxSkipBadLines].checked,
+		IncludeSource:    m.checkboxes[checkboxSourceColumns].checked,
+		Strict:           m.checkboxes[checkboxStrict].checked,
+		Validate:         m.checkboxes[checkboxValidate].checked,
+		Year:             m.year,
+		Encoding:         m.encoding,
+	}
+}
+
+// SetDefaults presets the collection year selector and the encoding from opts
+func (m *MenuModel) SetDefaults(opts parser.ProcessOptions) {
+	m.year = opts.Year
+	m.encoding = opts.Encoding
+}
+
+// yearPosition returns the cursor position of the year selector
+func (m MenuModel) yearPosition() int {
+	return len(m.choices) + len(m.checkboxes)
+}
+
+// previousYear steps the year selector back, from current to this year and then earlier years
+func previousYear(year int) int {
+	if year == 0 {
+		return time.Now().Year()
+	}
+	return year - 1
+}
+
+// nextYear steps the year selector forward, wrapping past this year to the current layouts
+func nextYear(year int) int {
+	if year == 0 || year >= time.Now().Year() {
+		return 0
+	}
+	return year + 1
+}
+
+// yearLabel describes a year selector value
+func yearLabel(year int) string {
+	if year == 0 {
+		return "Current layout"
+	}
+	return fmt.Sprintf("%d", year)
 }
 
 // GetSelectedOption returns the selected option type and any auto-detected files
@@ -144,49 +245,36 @@ func (m MenuModel) GetSelectedOption() (string, []string, error) {
 		return "", nil, fmt.Errorf("failed to get current directory: %w", err)
 	}
 
-	switch m.selectedIndex {
-	case 0: // Parse All Files
-		files, err := findAllSDRFiles(currentDir)
+	if m.selectedIndex == 0 { // Parse All Files
+		files, err := parser.FindSDRFiles(currentDir, "")
 		return "all", files, err
-	case 1: // Parse STUD File
-		files, err := findFilesByType(currentDir, "STUD")
-		return "stud", files, err
-	case 2: // Parse COUR File
-		files, err := findFilesByType(currentDir, "COUR")
-		return "cour", files, err
-	case 3: // Parse CREG File
-		files, err := findFilesByType(currentDir, "CREG")
-		return "creg", files, err
-	case 4: // Parse COMP File
-		files, err := findFilesByType(currentDir, "COMP")
-		return "comp", files, err
-	case 5: // Parse QUAL File
-		files, err := findFilesByType(currentDir, "QUAL")
-		return "qual", files, err
 	}
 
-	return "", nil, nil
-}
-
-// findAllSDRFiles finds all SDR files in the directory
-func findAllSDRFiles(dir string) ([]string, error) {
-	var files []string
-
-	fileTypes := []string{"STUD", "COUR", "CREG", "COMP", "QUAL"}
+	if m.selectedIndex-1 < len(m.fileTypes) {
+		fileType := m.fileTypes[m.selectedIndex-1]
+		files, err := parser.FindSDRFiles(currentDir, fileType)
+		return strings.ToLower(fileType), files, err
+	}
 
-	for _, fileType := range fileTypes {
-		typeFiles, err := findFilesByType(dir, fileType)
-		if err != nil {
-			return nil, err
-		}
-		files = append(files, typeFiles...)
+	switch m.selectedIndex - 1 - len(m.fileTypes) {
+	case 0: // Rebuild SDR File from CSV
+		files, err := findParsedCSVFiles(currentDir)
+		return rebuildOption, files, err
+	case 1: // Validate SDR Return
+		files, err := parser.FindSDRFiles(currentDir, "")
+		return validateReturnOption, files, err
 	}
 
-	return files, nil
+	return "", nil, nil
 }
 
-// findFilesByType finds files of a specific type (e.g., STUD, COUR, etc.)
-func findFilesByType(dir, fileType string) ([]string, error) {
+const (
+	rebuildOption        = "rebuild"  // Option type returned for "Rebuild SDR File from CSV"
+	validateReturnOption = "validate" // Option type returned for "Validate SDR Return"
+)
+
+// findParsedCSVFiles finds the _parsed.csv files written by earlier runs
+func findParsedCSVFiles(dir string) ([]string, error) {
 	var files []string
 
 	entries, err := os.ReadDir(dir)
@@ -195,15 +283,8 @@ func findFilesByType(dir, fileType string) ([]string, error) {
 	}
 
 	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-
-		name := entry.Name()
-		// Check if filename contains the file type and has .txt extension
-		if strings.Contains(strings.ToUpper(name), strings.ToUpper(fileType)) &&
-			strings.HasSuffix(strings.ToLower(name), ".txt") {
-			files = append(files, filepath.Join(dir, name))
+		if !entry.IsDir() && strings.HasSuffix(strings.ToLower(entry.Name()), "_parsed.csv") {
+			files = append(files, filepath.Join(dir, entry.Name()))
 		}
 	}
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -12,13 +13,13 @@ import (
 )
 
 type ProgressModel struct {
-	isComplete    bool
-	results       string
+	isComplete     bool
+	results        string
 	filesToProcess []string
-	currentFile   string
+	currentFile    string
 	processedFiles int
-	totalFiles    int
-	error         error
+	totalFiles     int
+	error          error
 }
 
 func NewProgressModel() ProgressModel {
@@ -46,28 +47,28 @@ func (m ProgressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m ProgressModel) View() string {
 	header := styles.HighlightStyle.Render(">> PROCESSING SDR FILES <<")
-	
+
 	if m.error != nil {
 		error := styles.ErrorStyle.Render("\nERROR: " + m.error.Error())
 		return styles.BoxStyle.Render(header + "\n" + error)
 	}
-	
+
 	var content string
 	if m.totalFiles > 1 {
 		content = styles.SubtitleStyle.Render(
 			"\nProcessing multiple files...\n" +
-			"Current: " + filepath.Base(m.currentFile) + "\n" +
-			"Progress: " + fmt.Sprintf("%d/%d files", m.processedFiles, m.totalFiles) + "\n\n" +
-			"[████████████████████████████████████████] Processing...")
+				"Current: " + filepath.Base(m.currentFile) + "\n" +
+				"Progress: " + fmt.Sprintf("%d/%d files", m.processedFiles, m.totalFiles) + "\n\n" +
+				"[████████████████████████████████████████] Processing...")
 	} else {
 		content = styles.SubtitleStyle.Render(
 			"\nAnalyzing file structure...\n" +
-			"Current: " + filepath.Base(m.currentFile) + "\n" +
-			"Extracting fields...\n" +
-			"Generating CSV...\n\n" +
-			"[████████████████████████████████████████] Processing...")
+				"Current: " + filepath.Base(m.currentFile) + "\n" +
+				"Extracting fields...\n" +
+				"Generating CSV...\n\n" +
+				"[████████████████████████████████████████] Processing...")
 	}
-	
+
 	return styles.BoxStyle.Render(header + "\n" + content)
 }
 
@@ -76,12 +77,16 @@ func (m ProgressModel) StartProcessing(filename string) tea.Cmd {
 }
 
 func (m ProgressModel) StartProcessingWithComparison(filename string, enableComparison bool) tea.Cmd {
+	return m.StartProcessingWithOptions(filename, parser.ProcessOptions{EnableComparison: enableComparison})
+}
+
+func (m ProgressModel) StartProcessingWithOptions(filename string, opts parser.ProcessOptions) tea.Cmd {
 	m.filesToProcess = []string{filename}
 	m.currentFile = filename
 	m.totalFiles = 1
 	m.processedFiles = 0
 	m.error = nil
-	return processFilesWithComparison([]string{filename}, enableComparison)
+	return processFilesWithOptions([]string{filename}, opts)
 }
 
 func (m ProgressModel) StartProcessingMultiple(files []string) tea.Cmd {
@@ -89,6 +94,22 @@ func (m ProgressModel) StartProcessingMultiple(files []string) tea.Cmd {
 }
 
 func (m ProgressModel) StartProcessingMultipleWithComparison(files []string, enableComparison bool) tea.Cmd {
+	return m.StartProcessingMultipleWithOptions(files, parser.ProcessOptions{EnableComparison: enableComparison})
+}
+
+func (m ProgressModel) StartProcessingMultipleWithOptions(files []string, opts parser.ProcessOptions) tea.Cmd {
+	m.filesToProcess = files
+	m.totalFiles = len(files)
+	m.processedFiles = 0
+	m.error = nil
+	if len(files) > 0 {
+		m.currentFile = files[0]
+	}
+	return processFilesWithOptions(files, opts)
+}
+
+// StartRebuildingWithOptions rebuilds fixed-width SDR files from CSV files
+func (m ProgressModel) StartRebuildingWithOptions(files []string, opts parser.ProcessOptions) tea.Cmd {
 	m.filesToProcess = files
 	m.totalFiles = len(files)
 	m.processedFiles = 0
@@ -96,7 +117,19 @@ func (m ProgressModel) StartProcessingMultipleWithComparison(files []string, ena
 	if len(files) > 0 {
 		m.currentFile = files[0]
 	}
-	return processFilesWithComparison(files, enableComparison)
+	return rebuildFilesWithOptions(files, opts)
+}
+
+// StartValidatingReturn checks the SDR files of one return against each other
+func (m ProgressModel) StartValidatingReturn(files []string, opts parser.ProcessOptions) tea.Cmd {
+	m.filesToProcess = files
+	m.totalFiles = len(files)
+	m.processedFiles = 0
+	m.error = nil
+	if len(files) > 0 {
+		m.currentFile = files[0]
+	}
+	return validateReturnWithOptions(files, opts)
 }
 
 // ProcessCompleteMsg is sent when processing is complete
@@ -118,40 +151,211 @@ func processFiles(files []string) tea.Cmd {
 
 // processFilesWithComparison processes one or more files with optional comparison mode
 func processFilesWithComparison(files []string, enableComparison bool) tea.Cmd {
+	return processFilesWithOptions(files, parser.ProcessOptions{EnableComparison: enableComparison})
+}
+
+// maxLineErrorsShown limits how many skipped lines are listed per file
+const maxLineErrorsShown = 5
+
+// reportName is the base name of the validation report files written to the current directory
+const reportName = "validation_report"
+
+// processFilesWithOptions processes one or more files with the given options
+func processFilesWithOptions(files []string, opts parser.ProcessOptions) tea.Cmd {
 	return func() tea.Msg {
 		var results []string
 		var processingError error
-		
+
 		// Get current directory for output
 		currentDir, err := os.Getwd()
 		if err != nil {
 			return ProcessCompleteMsg{Error: fmt.Errorf("failed to get current directory: %w", err)}
 		}
-		
+
+		report := parser.NewValidationReport()
 		for i, file := range files {
 			// Send progress update
 			if i > 0 {
 				// This is a bit of a hack - we can't send multiple messages from one command
 				// In a real implementation, you'd want to use a proper progress system
 			}
-			
-			result := parser.ProcessFileWithComparison(file, currentDir, enableComparison)
-			if result.Success {
-				results = append(results, fmt.Sprintf("✓ %s → %s (%d records)", 
-					filepath.Base(result.InputFile), 
-					filepath.Base(result.OutputFile), 
-					result.RecordCount))
+
+			result := parser.ProcessFileWithOptions(file, currentDir, opts)
+			report.AddResult(result)
+			if result.Success && result.Partial {
+				results = append(results, fmt.Sprintf("⚠ %s → %s (%d records, %d lines skipped)",
+					filepath.Base(result.InputFile),
+					filepath.Base(result.OutputFile),
+					result.RecordCount,
+					len(result.LineErrors)))
+				for j, lineErr := range result.LineErrors {
+					if j == maxLineErrorsShown {
+						results = append(results, fmt.Sprintf("    ... and %d more", len(result.LineErrors)-maxLineErrorsShown))
+						break
+					}
+					results = append(results, "    "+lineErr.Error())
+				}
+			} else if result.Success {
+				results = append(results, fmt.Sprintf("✓ %s → %s (%d records, %s)",
+					filepath.Base(result.InputFile),
+					filepath.Base(result.OutputFile),
+					result.RecordCount,
+					describeLayout(result)))
+				for j, warning := range result.Warnings {
+					if j == maxLineErrorsShown {
+						results = append(results, fmt.Sprintf("    ... and %d more warnings", len(result.Warnings)-maxLineErrorsShown))
+						break
+					}
+					results = append(results, "    warning: "+warning.Error())
+				}
 			} else {
-				results = append(results, fmt.Sprintf("✗ %s - ERROR: %s", 
-					filepath.Base(result.InputFile), 
+				results = append(results, fmt.Sprintf("✗ %s - ERROR: %s",
+					filepath.Base(result.InputFile),
 					result.Error.Error()))
 				processingError = result.Error
 			}
+
+			for _, warning := range result.ComparisonWarnings {
+				results = append(results, "    warning: "+warning)
+			}
+			if len(result.Findings) > 0 {
+				results = append(results, fmt.Sprintf("    %d validation finding(s):", len(result.Findings)))
+				for j, finding := range result.Findings {
+					if j == maxLineErrorsShown {
+						results = append(results, fmt.Sprintf("    ... and %d more findings", len(result.Findings)-maxLineErrorsShown))
+						break
+					}
+					results = append(results, fmt.Sprintf("    %s: %s", finding.Severity, finding))
+				}
+			}
+		}
+
+		if opts.Validate {
+			results = append(results, saveReport(report, currentDir))
+		}
+
+		return ProcessCompleteMsg{
+			Results: results,
+			Error:   processingError,
+		}
+	}
+}
+
+// describeLayout summarises the detected file type, its confidence and the collection year used
+func describeLayout(result parser.ProcessorResult) string {
+	layout := fmt.Sprintf("%s %.0f%%", result.FileType, result.Confidence*100)
+	if result.Year != 0 {
+		layout += fmt.Sprintf(", %d layout", result.Year)
+	}
+	if result.Encoding != "" && result.Encoding != parser.EncodingUTF8 {
+		layout += ", " + result.Encoding.String()
+	}
+	return layout
+}
+
+// rebuildFilesWithOptions converts one or more CSV files back into fixed-width SDR files
+func rebuildFilesWithOptions(files []string, opts parser.ProcessOptions) tea.Cmd {
+	return func() tea.Msg {
+		var results []string
+		var processingError error
+
+		currentDir, err := os.Getwd()
+		if err != nil {
+			return ProcessCompleteMsg{Error: fmt.Errorf("failed to get current directory: %w", err)}
+		}
+
+		for _, file := range files {
+			result := parser.RebuildFile(file, currentDir, opts)
+			if result.Success {
+				results = append(results, fmt.Sprintf("✓ %s → %s (%d lines, %s)",
+					filepath.Base(result.InputFile),
+					filepath.Base(result.OutputFile),
+					result.RecordCount,
+					result.FileType))
+				continue
+			}
+
+			results = append(results, fmt.Sprintf("✗ %s - ERROR: %s",
+				filepath.Base(result.InputFile),
+				result.Error.Error()))
+			for j, lineErr := range result.LineErrors {
+				if j == maxLineErrorsShown {
+					results = append(results, fmt.Sprintf("    ... and %d more", len(result.LineErrors)-maxLineErrorsShown))
+					break
+				}
+				results = append(results, "    "+lineErr.Error())
+			}
+			processingError = result.Error
 		}
-		
+
 		return ProcessCompleteMsg{
 			Results: results,
 			Error:   processingError,
 		}
 	}
 }
+
+// maxSamplesShown limits how many findings of each rule are listed for a return
+const maxSamplesShown = 5
+
+// validateReturnWithOptions loads the files as one return and lists the findings by rule
+func validateReturnWithOptions(files []string, opts parser.ProcessOptions) tea.Cmd {
+	return func() tea.Msg {
+		result := parser.ValidateReturn(files, opts)
+		report := parser.NewValidationReport()
+		report.AddReturn(result)
+		if result.Error != nil {
+			return ProcessCompleteMsg{
+				Results: []string{"✗ Return - ERROR: " + result.Error.Error()},
+				Error:   result.Error,
+			}
+		}
+
+		var names []string
+		for _, file := range result.Files {
+			names = append(names, filepath.Base(file))
+		}
+
+		var results []string
+		if result.Blocked() {
+			results = append(results, fmt.Sprintf("✗ Return %s: %d records, %d finding(s); fix the blocking ones before uploading",
+				strings.Join(names, ", "), result.RecordCount, len(result.Findings)))
+		} else if len(result.Findings) == 0 {
+			results = append(results, fmt.Sprintf("✓ Return %s: %d records, no findings", strings.Join(names, ", "), result.RecordCount))
+		} else {
+			results = append(results, fmt.Sprintf("⚠ Return %s: %d records, %d finding(s)", strings.Join(names, ", "), result.RecordCount, len(result.Findings)))
+		}
+		// Blocking findings are listed first
+		groups := parser.GroupFindings(result.Findings, maxSamplesShown)
+		sort.SliceStable(groups, func(i, j int) bool {
+			return groups[i].Severity == parser.SeverityBlocking && groups[j].Severity != parser.SeverityBlocking
+		})
+		for _, group := range groups {
+			results = append(results, fmt.Sprintf("  %s %s (%d): %s", group.Severity, group.Rule, group.Count, group.Title))
+			for _, finding := range group.Samples {
+				results = append(results, "    "+finding.String())
+			}
+			if group.Count > len(group.Samples) {
+				results = append(results, fmt.Sprintf("    ... and %d more", group.Count-len(group.Samples)))
+			}
+		}
+
+		if currentDir, err := os.Getwd(); err == nil {
+			results = append(results, saveReport(report, currentDir))
+		}
+		return ProcessCompleteMsg{Results: results}
+	}
+}
+
+// saveReport writes the validation report as CSV, JSON and HTML and describes the outcome
+func saveReport(report *parser.ValidationReport, dir string) string {
+	paths, err := report.Save(dir, reportName)
+	if err != nil {
+		return "✗ Report - ERROR: " + err.Error()
+	}
+	var names []string
+	for _, path := range paths {
+		names = append(names, filepath.Base(path))
+	}
+	return "Report: " + strings.Join(names, ", ")
+}
@@ -1,16 +1,93 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
-	// "os"
+	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/unamelo/oh-no-sdr/internal/parser"
 	"github.com/unamelo/oh-no-sdr/internal/ui/models"
 )
 
 func main() {
+	year := flag.Int("year", 0, "collection year whose layout to use, for layouts of earlier years loaded from spec files (default: the current layouts)")
+	specDir := flag.String("specs", "", "directory of JSON spec files overriding the built-in layouts (default: the user config directory, if present)")
+	exportDir := flag.String("export-specs", "", "write the built-in layouts as JSON spec files to this directory and exit")
+	codeDir := flag.String("codes", "", "directory of CSV code tables overriding the built-in ones (default: the user config directory, if present)")
+	exportCodesDir := flag.String("export-codes", "", "write the built-in code tables as CSV files to this directory and exit")
+	joinDir := flag.String("joins", "", "directory of JSON join files replacing the built-in comparison joins (default: the user config directory, if present)")
+	exportJoinsDir := flag.String("export-joins", "", "write the built-in comparison joins as a JSON join file to this directory and exit")
+	ruleDir := flag.String("rules", "", "directory of JSON rule files with extra validation rules (default: the user config directory, if present)")
+	encodingName := flag.String("encoding", "auto", "character encoding of the SDR files: auto, utf-8, windows-1252 or latin-1")
+	flag.Parse()
+
+	encoding, err := parser.ParseEncoding(*encodingName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *exportDir != "" {
+		paths, err := parser.ExportSpecs(*exportDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, path := range paths {
+			fmt.Println(path)
+		}
+		return
+	}
+
+	if *exportCodesDir != "" {
+		paths, err := parser.ExportCodeSets(*exportCodesDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, path := range paths {
+			fmt.Println(path)
+		}
+		return
+	}
+
+	if *exportJoinsDir != "" {
+		path, err := parser.ExportJoins(*exportJoinsDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(path)
+		return
+	}
+
+	// Code tables first, so spec files can refer to the tables they add
+	if err := loadOverrides(*codeDir, parser.DefaultCodeSetDir, func(dir string) error {
+		_, err := parser.LoadCodeSetDir(dir)
+		return err
+	}); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadOverrides(*specDir, parser.DefaultSpecDir, func(dir string) error {
+		_, err := parser.LoadSpecDir(dir)
+		return err
+	}); err != nil {
+		log.Fatal(err)
+	}
+	// Joins and rules last, as they are checked against the fields of the specs in use
+	if err := loadOverrides(*joinDir, parser.DefaultJoinDir, func(dir string) error {
+		_, err := parser.LoadJoinDir(dir)
+		return err
+	}); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadOverrides(*ruleDir, parser.DefaultRuleDir, func(dir string) error {
+		_, err := parser.LoadRuleDir(dir)
+		return err
+	}); err != nil {
+		log.Fatal(err)
+	}
+
 	p := tea.NewProgram(
-		models.NewMainModel(),
+		models.NewMainModelWithOptions(parser.ProcessOptions{Year: *year, Encoding: encoding}),
 		tea.WithAltScreen(), // Use full screen
 	)
 
@@ -18,3 +95,20 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// loadOverrides installs the overrides in dir with load, or those in the default
+// directory if dir is empty and the default exists
+func loadOverrides(dir string, defaultDir func() (string, error), load func(dir string) error) error {
+	if dir == "" {
+		var err error
+		dir, err = defaultDir()
+		if err != nil {
+			return nil // No config directory, so no overrides
+		}
+		if _, err := os.Stat(dir); err != nil {
+			return nil
+		}
+	}
+
+	return load(dir)
+}